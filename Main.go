@@ -2,8 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	. "github.com/Travmatth/taskmaster/log"
 	PARSE "github.com/Travmatth/taskmaster/parse"
@@ -13,64 +17,235 @@ import (
 )
 
 type Opts struct {
-	Config string
-	Log    string
-	Level  string
+	Config         string
+	Log            string
+	Level          string
+	Subreaper      bool
+	State          string
+	Adopt          bool
+	UpgradeFd      int
+	Socket         string
+	Command        string
+	Console        bool
+	LogFormat      string
+	Syslog         string
+	SyslogFacility string
+	SyslogTag      string
+	SyslogFormat   string
 }
 
 func parseOpts(args []string) (opts Opts, ok bool) {
 	ok = true
-	if len(args) == 3 {
+	opts.UpgradeFd = -1
+	positional := []string{args[0]}
+	for n := 1; n < len(args); n++ {
+		switch arg := args[n]; {
+		case arg == "--subreaper":
+			opts.Subreaper = true
+		case arg == "--adopt":
+			opts.Adopt = true
+		case arg == "--console":
+			opts.Console = true
+		case arg == "--state" && n+1 < len(args):
+			n++
+			opts.State = args[n]
+		case arg == "--socket" && n+1 < len(args):
+			n++
+			opts.Socket = args[n]
+		case arg == "--log-format" && n+1 < len(args):
+			n++
+			opts.LogFormat = args[n]
+		case arg == "--syslog" && n+1 < len(args):
+			n++
+			opts.Syslog = args[n]
+		case arg == "--syslog-facility" && n+1 < len(args):
+			n++
+			opts.SyslogFacility = args[n]
+		case arg == "--syslog-tag" && n+1 < len(args):
+			n++
+			opts.SyslogTag = args[n]
+		case arg == "--syslog-format" && n+1 < len(args):
+			n++
+			opts.SyslogFormat = args[n]
+		case arg == "--command" && n+1 < len(args):
+			n++
+			opts.Command = args[n]
+		case arg == SVSR.UPGRADEFLAG && n+1 < len(args):
+			n++
+			if fd, err := strconv.Atoi(args[n]); err != nil {
+				ok = false
+				return
+			} else {
+				opts.UpgradeFd = fd
+			}
+		case strings.HasPrefix(arg, "--"):
+			ok = false
+			return
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if opts.Command != "" {
+		ok = opts.Socket != "" && len(positional) == 1
+		return
+	} else if len(positional) == 3 {
 		opts.Level = "4"
-	} else if len(args) == 4 {
-		opts.Level = args[3]
+	} else if len(positional) == 4 {
+		opts.Level = positional[3]
 	} else {
 		ok = false
 		return
 	}
-	opts.Config, opts.Log = args[1], args[2]
+	if opts.Syslog == "" && opts.SyslogFacility+opts.SyslogTag+opts.SyslogFormat != "" {
+		ok = false
+		return
+	}
+	if opts.Adopt && opts.State == "" {
+		ok = false
+		return
+	}
+	opts.Config, opts.Log = positional[1], positional[2]
 	return
 }
 
+// openLogger appends to the log when resuming after an upgrade, copying it
+// to syslog when given
+func openLogger(opts Opts) error {
+	if opts.Syslog != "" {
+		if s, err := NewSyslog(opts.Syslog, opts.SyslogFacility, opts.SyslogTag,
+			opts.SyslogFormat); err != nil {
+			return err
+		} else {
+			SetSyslog(s)
+		}
+	}
+	if opts.UpgradeFd != -1 {
+		return ResumeLogger(opts.Log, opts.Level, opts.LogFormat)
+	}
+	return NewLogger(opts.Log, opts.Level, opts.LogFormat)
+}
+
 //ManageSignals handles the responses to signals sent to the program
 func ManageSignals(s *SVSR.Supervisor, config string, c chan os.Signal) {
 	sig := <-c
 	if sig == syscall.SIGHUP {
 		if reloadJobs, err := PARSE.LoadJobsFromFile(config); err != nil {
 			Log.Info("Error reloading configuration", err)
-			s.StopAllJobs(true)
+			s.Shutdown()
 			os.Exit(1)
 		} else {
 			Log.Info("Supervisor: signal", sig, "received, reloading", config)
 			s.Reload(reloadJobs, false)
 		}
+	} else if sig == syscall.SIGUSR1 {
+		Log.Info("Supervisor: signal", sig, "received, reopening logs")
+		if err := s.ReopenLogs(); err != nil {
+			Log.Info("Supervisor: reopening logs failed:", err)
+		}
+	} else if sig == syscall.SIGUSR2 {
+		Log.Info("Supervisor: signal", sig, "received, upgrading")
+		if err := s.Upgrade(); err != nil {
+			Log.Info("Supervisor: upgrade failed:", err)
+		}
+	} else if sig == syscall.SIGINT && s.Interrupt() {
+		Log.Info("Supervisor: signal", sig, "received, interrupting ui")
 	} else if sig == syscall.SIGTERM || sig == syscall.SIGINT {
 		Log.Info("Supervisor: exit signal received, shutting down")
-		s.StopAllJobs(true)
+		s.Shutdown()
 		os.Exit(0)
 	}
 	go ManageSignals(s, config, c)
 }
 
 func main() {
-	if opts, ok := parseOpts(os.Args); ok == false {
-		fmt.Println("Usage: ./taskmaster <Config_File> <Log_File> [Log_Level]")
+	opts, ok := parseOpts(os.Args)
+	PARSE.ConsoleOutput = opts.Console
+	if ok == false {
+		fmt.Println("Usage: ./taskmaster [Options] <Config_File> <Log_File> [Log_Level]")
 		fmt.Println("\tConfig_File: Procfile you wish to run")
 		fmt.Println("\tLog_File: Log file you wish to use")
 		levels := "0 CRITICAL, 1 ERROR, 2 WARNING, 3 NOTICE, 4 INFO, 5 DEBUG"
 		fmt.Println("\tLog_Level: ", levels)
+		fmt.Println("\t\tor per package, e.g. \"INFO,instance=DEBUG\"")
+		fmt.Println("Options:")
+		fmt.Println("\t--subreaper: adopt & reap orphaned descendants of jobs")
+		fmt.Println("\t--state <File>: persist running instances to File")
+		fmt.Println("\t--adopt: re-attach to the running instances in the state file")
+		fmt.Println("\t--socket <File>: accept ui commands on a unix socket at File")
+		fmt.Println("\t--console: show the output of jobs on stdout, labelled by instance")
+		fmt.Println("\t--log-format <text|json>: write the log as text or a json object per line")
+		fmt.Println("\t--syslog <Address>: copy the log to syslog at /dev/log, unix://Path, udp://Host:Port or tcp://Host:Port")
+		fmt.Println("\t--syslog-facility <Name>: syslog facility, daemon by default")
+		fmt.Println("\t--syslog-tag <Tag>: syslog tag of the log, taskmaster by default")
+		fmt.Println("\t--syslog-format <rfc3164|rfc5424>: syslog message format, rfc3164 by default")
+		fmt.Println("Client Usage: ./taskmaster --socket <File> --command <Command>")
+		fmt.Println("\tsend a ui command to a running taskmaster & print the response")
+	} else if opts.Command != "" {
+		var in io.Reader
+		command := strings.Fields(strings.ToLower(opts.Command))
+		if strings.HasPrefix(strings.ToLower(opts.Command), "attach") {
+			in = os.Stdin
+		} else if len(command) > 0 && command[0] == "tail" &&
+			strings.Contains(" "+strings.Join(command, " ")+" ", " -f ") {
+			// an unwritten pipe holds the connection open, taskmaster
+			// stops following output once it closes on exit
+			in, _ = io.Pipe()
+		}
+		err := UI.Control(opts.Socket, opts.Command, in, os.Stdout)
+		if exit, ok := err.(*UI.ExitError); ok && exit.Code > 0 {
+			// the exit code of a job run with start --wait
+			os.Exit(exit.Code)
+		} else if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	} else if jobs, err := PARSE.LoadJobsFromFile(opts.Config); err != nil {
 		fmt.Println(err)
-	} else if err := NewLogger(opts.Log, opts.Level); err != nil {
+	} else if err := openLogger(opts); err != nil {
 		fmt.Println(err)
 	} else {
 		s := SVSR.NewSupervisor(opts.Config, opts.Log,
 			SVSR.NewManager(), SIG.InitSignals())
+		if opts.Subreaper || os.Getpid() == 1 {
+			if err := s.Subreap(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		if opts.Socket != "" {
+			// before any job is adopted or started, so a second
+			// taskmaster on the same socket exits untouched
+			if _, err := UI.ServeControl(s, opts.Socket); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		s.StateFile = opts.State
+		if opts.UpgradeFd != -1 {
+			if state, err := s.ResumeUpgrade(opts.UpgradeFd); err != nil {
+				Log.Info("Supervisor: unable to resume upgrade:", err)
+			} else {
+				Log.Info("Supervisor: upgraded, resuming", len(state.Instances),
+					"instance(s)")
+				s.ResumeSockets(jobs)
+				s.ResumeOutput(jobs)
+				SVSR.AdoptInstances(jobs, state)
+			}
+		} else if opts.Adopt {
+			if state, err := SVSR.LoadState(opts.State); err != nil {
+				Log.Info("Supervisor: no state to adopt:", err)
+			} else {
+				SVSR.AdoptInstances(jobs, state)
+			}
+		}
+		if s.StateFile != "" {
+			go s.PersistState(time.Second)
+		}
 		go ManageSignals(s, opts.Config, s.SigCh)
 		for {
 			if err := s.Reload(jobs, false); err != nil {
 				Log.Info("Error reloading configuration", err)
-				s.StopAllJobs(true)
+				s.Shutdown()
 				os.Exit(1)
 			}
 			f := UI.NewFrontend(s)
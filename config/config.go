@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"reflect"
 )
 
 /*
@@ -13,23 +14,91 @@ type Redirections struct {
 	Stderr string `json:"Stderr"`
 }
 
+/*
+ * StopStep stores one signal of a stop sequence and how long to wait for
+ * the process to exit before escalating to the next step
+ */
+type StopStep struct {
+	Signal string `json:"Signal" yaml:"signal"`
+	Wait   string `json:"Wait" yaml:"wait"`
+}
+
+/*
+ * Socket stores a listening socket bound by taskmaster for the job
+ */
+type Socket struct {
+	Name    string `json:"Name" yaml:"name"`
+	Address string `json:"Address" yaml:"address"`
+	Backlog string `json:"Backlog" yaml:"backlog"`
+	Mode    string `json:"Mode" yaml:"mode"`
+}
+
+/*
+ * Watch stores the files watched for changes and the action taken on the
+ * job's instances when they change
+ */
+type Watch struct {
+	Paths    []string `json:"Paths" yaml:"paths"`
+	Exclude  []string `json:"Exclude" yaml:"exclude"`
+	Debounce string   `json:"Debounce" yaml:"debounce"`
+	Action   string   `json:"Action" yaml:"action"`
+}
+
+/*
+ * LogRotation stores when the files stdout & stderr are redirected to are
+ * rotated and how many rotated files are kept
+ */
+type LogRotation struct {
+	MaxBytes string `json:"MaxBytes" yaml:"maxBytes"`
+	Backups  string `json:"Backups" yaml:"backups"`
+	Compress string `json:"Compress" yaml:"compress"`
+}
+
 /*
  * JobConfig represents the config struct loaded from yaml
  */
 type JobConfig struct {
-	ID            string `json:"ID" yaml:"id"`
-	Command       string `json:"Command" yaml:"command"`
-	Instances     string `json:"Instances" yaml:"instances"`
-	AtLaunch      string `json:"AtLaunch" yaml:"atLaunch"`
-	RestartPolicy string `json:"RestartPolicy" yaml:"restartPolicy"`
-	ExpectedExit  string `json:"ExpectedExit" yaml:"expectedExit"`
-	StartCheckup  string `json:"StartCheckup" yaml:"startCheckup"`
-	MaxRestarts   string `json:"MaxRestarts" yaml:"maxRestarts"`
-	StopSignal    string `json:"StopSignal" yaml:"stopSignal"`
-	StopTimeout   string `json:"StopTimeout" yaml:"stopTimeout"`
-	EnvVars       string `json:"EnvVars" yaml:"envVars"`
-	WorkingDir    string `json:"WorkingDir" yaml:"workingDir"`
-	Umask         string `json:"Umask" yaml:"umask"`
+	ID                string      `json:"ID" yaml:"id"`
+	Command           string      `json:"Command" yaml:"command"`
+	Instances         string      `json:"Instances" yaml:"instances"`
+	AtLaunch          string      `json:"AtLaunch" yaml:"atLaunch"`
+	RestartPolicy     string      `json:"RestartPolicy" yaml:"restartPolicy"`
+	ExpectedExit      string      `json:"ExpectedExit" yaml:"expectedExit"`
+	StartCheckup      string      `json:"StartCheckup" yaml:"startCheckup"`
+	MaxRestarts       string      `json:"MaxRestarts" yaml:"maxRestarts"`
+	StopSignal        string      `json:"StopSignal" yaml:"stopSignal"`
+	StopTimeout       string      `json:"StopTimeout" yaml:"stopTimeout"`
+	StopSequence      []StopStep  `json:"StopSequence" yaml:"stopSequence"`
+	EnvVars           string      `json:"EnvVars" yaml:"envVars"`
+	WorkingDir        string      `json:"WorkingDir" yaml:"workingDir"`
+	Umask             string      `json:"Umask" yaml:"umask"`
+	Schedule          string      `json:"Schedule" yaml:"schedule"`
+	OverlapPolicy     string      `json:"OverlapPolicy" yaml:"overlapPolicy"`
+	Type              string      `json:"Type" yaml:"type"`
+	DependsOn         []string    `json:"DependsOn" yaml:"dependsOn"`
+	Sockets           []Socket    `json:"Sockets" yaml:"sockets"`
+	Lazy              string      `json:"Lazy" yaml:"lazy"`
+	Watch             Watch       `json:"Watch" yaml:"watch"`
+	MaxRssMB          string      `json:"MaxRssMB" yaml:"maxRssMB"`
+	MaxCpuPercent     string      `json:"MaxCpuPercent" yaml:"maxCpuPercent"`
+	MaxCpuDuration    string      `json:"MaxCpuDuration" yaml:"maxCpuDuration"`
+	MaxOpenFiles      string      `json:"MaxOpenFiles" yaml:"maxOpenFiles"`
+	WatchdogInterval  string      `json:"WatchdogInterval" yaml:"watchdogInterval"`
+	MaxRuntime        string      `json:"MaxRuntime" yaml:"maxRuntime"`
+	Interactive       string      `json:"Interactive" yaml:"interactive"`
+	TTY               string      `json:"TTY" yaml:"tty"`
+	TTYSize           string      `json:"TTYSize" yaml:"ttySize"`
+	LogRotation       LogRotation `json:"LogRotation" yaml:"logRotation"`
+	Console           string      `json:"Console" yaml:"console"`
+	BufferLines       string      `json:"BufferLines" yaml:"bufferLines"`
+	BufferBytes       string      `json:"BufferBytes" yaml:"bufferBytes"`
+	StdoutSyslog      string      `json:"StdoutSyslog" yaml:"stdoutSyslog"`
+	StderrSyslog      string      `json:"StderrSyslog" yaml:"stderrSyslog"`
+	OutputTimestamps  string      `json:"OutputTimestamps" yaml:"outputTimestamps"`
+	OutputPrefix      string      `json:"OutputPrefix" yaml:"outputPrefix"`
+	MaxLinesPerSecond string      `json:"MaxLinesPerSecond" yaml:"maxLinesPerSecond"`
+	MaxLinesBurst     string      `json:"MaxLinesBurst" yaml:"maxLinesBurst"`
+	MaxLineLength     string      `json:"MaxLineLength" yaml:"maxLineLength"`
 	Redirections
 }
 
@@ -50,14 +119,63 @@ func (c JobConfig) Same(cfg *JobConfig) bool {
 		c.EnvVars != cfg.EnvVars ||
 		c.WorkingDir != cfg.WorkingDir ||
 		c.Umask != cfg.Umask ||
+		c.Schedule != cfg.Schedule ||
+		c.OverlapPolicy != cfg.OverlapPolicy ||
+		c.Type != cfg.Type ||
+		!sameList(c.DependsOn, cfg.DependsOn) ||
+		!sameList(c.Sockets, cfg.Sockets) ||
+		c.Lazy != cfg.Lazy ||
+		!sameWatch(c.Watch, cfg.Watch) ||
+		c.MaxRssMB != cfg.MaxRssMB ||
+		c.MaxCpuPercent != cfg.MaxCpuPercent ||
+		c.MaxCpuDuration != cfg.MaxCpuDuration ||
+		c.MaxOpenFiles != cfg.MaxOpenFiles ||
+		c.WatchdogInterval != cfg.WatchdogInterval ||
+		c.MaxRuntime != cfg.MaxRuntime ||
+		c.Interactive != cfg.Interactive ||
+		c.TTY != cfg.TTY ||
+		c.TTYSize != cfg.TTYSize ||
+		c.LogRotation != cfg.LogRotation ||
+		c.Console != cfg.Console ||
+		c.BufferLines != cfg.BufferLines ||
+		c.BufferBytes != cfg.BufferBytes ||
+		c.StdoutSyslog != cfg.StdoutSyslog ||
+		c.StderrSyslog != cfg.StderrSyslog ||
+		c.OutputTimestamps != cfg.OutputTimestamps ||
+		c.OutputPrefix != cfg.OutputPrefix ||
+		c.MaxLinesPerSecond != cfg.MaxLinesPerSecond ||
+		c.MaxLinesBurst != cfg.MaxLinesBurst ||
+		c.MaxLineLength != cfg.MaxLineLength ||
 		c.Redirections.Stdin != cfg.Redirections.Stdin ||
 		c.Redirections.Stdout != cfg.Redirections.Stdout ||
-		c.Redirections.Stderr != cfg.Redirections.Stderr {
+		c.Redirections.Stderr != cfg.Redirections.Stderr ||
+		!sameList(c.StopSequence, cfg.StopSequence) {
 		return false
 	}
 	return true
 }
 
+/*
+ * sameList compares two lists of the same type element by element, a list
+ * left out of the configuration being the same as an empty one
+ */
+func sameList(a, b interface{}) bool {
+	if reflect.ValueOf(a).Len() == 0 && reflect.ValueOf(b).Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+/*
+ * sameWatch compares two watch configurations field by field
+ */
+func sameWatch(a, b Watch) bool {
+	return sameList(a.Paths, b.Paths) &&
+		sameList(a.Exclude, b.Exclude) &&
+		a.Debounce == b.Debounce &&
+		a.Action == b.Action
+}
+
 /*
  * String is the printed representation of the struct
  */
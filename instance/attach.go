@@ -0,0 +1,142 @@
+package instance
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Travmatth/taskmaster/output"
+)
+
+/*
+ * openPipes creates the pipes capturing the output of a process, and its
+ * input if it is interactive, returning the ends to be given to the process
+ * as its standard input, output & error, nil where it keeps its redirection
+ */
+func (i *Instance) openPipes() ([]*os.File, error) {
+	var parent, child []*os.File
+	for n := 0; n < 3; n++ {
+		if n == 0 && !i.Interactive {
+			parent, child = append(parent, nil), append(child, nil)
+			continue
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeFiles(parent)
+			closeFiles(child)
+			return nil, err
+		} else if n == 0 {
+			parent, child = append(parent, w), append(child, r)
+		} else {
+			parent, child = append(parent, r), append(child, w)
+		}
+	}
+	i.stdin, i.captured = parent[0], parent[1:]
+	return child, nil
+}
+
+/*
+ * startCapture closes taskmaster's copies of the process's ends of the pipes
+ * and copies its output to the broadcasters, through the limiter if the job
+ * limits its output
+ */
+func (i *Instance) startCapture(child []*os.File) {
+	closeFiles(child)
+	streams := []*output.Broadcaster{i.Stdout, i.Stderr}
+	i.drained = nil
+	if i.Recent != nil {
+		i.recentMark = i.Recent.Total()
+	}
+	if i.Limiter != nil && i.limited == nil {
+		i.limited = []*output.LimitWriter{
+			i.Limiter.Writer(i.Stdout), i.Limiter.Writer(i.Stderr)}
+	}
+	for n, r := range i.captured {
+		done := make(chan struct{})
+		i.drained = append(i.drained, done)
+		var w io.Writer = streams[n]
+		if i.limited != nil {
+			w = i.limited[n]
+		}
+		go output.Capture(r, w, done)
+	}
+}
+
+/*
+ * closePipes releases the pipes of a process which failed to start
+ */
+func (i *Instance) closePipes(child []*os.File) {
+	closeFiles(child)
+	closeFiles(i.captured)
+	closeFiles([]*os.File{i.stdin})
+	i.stdin, i.captured, i.tty = nil, nil, nil
+	i.closeLogs()
+}
+
+/*
+ * drain waits for the output of an exited process to be copied, giving up
+ * after a second in case a descendant still holds the pipes open, marks any
+ * lines suppressed, writes out any partial line shown on the console, and
+ * closes its input & log files
+ */
+func (i *Instance) drain() {
+	deadline := time.Now().Add(time.Second)
+	for _, done := range i.drained {
+		select {
+		case <-done:
+		case <-time.After(time.Until(deadline)):
+		}
+	}
+	for _, w := range i.limited {
+		w.Flush()
+	}
+	for _, w := range i.Lines {
+		w.Flush()
+	}
+	i.Mutex.Lock()
+	closeFiles([]*os.File{i.stdin})
+	i.stdin, i.captured, i.drained, i.tty = nil, nil, nil, nil
+	i.closeLogs()
+	i.Mutex.Unlock()
+}
+
+/*
+ * WriteInput writes p to the standard input of the running process
+ */
+func (i *Instance) WriteInput(p []byte) error {
+	i.Mutex.RLock()
+	stdin := i.stdin
+	i.Mutex.RUnlock()
+	// the lock is not held while writing, as a process which is not reading
+	// its input would block the write
+	if stdin == nil {
+		return errors.New("process not accepting input")
+	}
+	_, err := stdin.Write(p)
+	return err
+}
+
+/*
+ * AttachOutput copies the output of the process to w until the returned
+ * function is called, or until w falls too far behind the process
+ */
+func (i *Instance) AttachOutput(w io.Writer) func() {
+	detachOut := i.Stdout.AttachClient(w, nil)
+	detachErr := i.Stderr.AttachClient(w, nil)
+	return func() {
+		detachOut()
+		detachErr()
+	}
+}
+
+/*
+ * closeFiles closes each of the non nil files
+ */
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
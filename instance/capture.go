@@ -0,0 +1,163 @@
+package instance
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/Travmatth/taskmaster/output"
+)
+
+/*
+ * openLogs opens the log files the process's stdout & stderr are written to,
+ * appending to the output of earlier runs. A stream redirected to the same
+ * file as stdout shares its log file, each stream framing its own lines
+ */
+func (i *Instance) openLogs() error {
+	streams := []*output.Broadcaster{i.Stdout, i.Stderr}
+	names := []string{"stdout", "stderr"}
+	i.logs = make([]*output.RotatingFile, len(i.LogFiles))
+	i.framers = nil
+	for n, path := range i.LogFiles {
+		var sink io.Writer
+		if path != "" && n > 0 && path == i.LogFiles[0] {
+			sink = i.logs[0]
+		} else if path != "" {
+			f, err := output.OpenRotating(path, i.Rotation)
+			if err != nil {
+				i.closeLogs()
+				return err
+			}
+			i.logs[n], sink = f, f
+		}
+		if sink != nil && i.Framing.Enabled() {
+			framer := i.Framing.Writer(sink, i.frame(names[n]))
+			i.framers = append(i.framers, framer)
+			sink = framer
+		}
+		streams[n].SetSink(sink)
+	}
+	return nil
+}
+
+/*
+ * discardOutput points the stdout & stderr of a process whose output is not
+ * captured at null rather than leaving them closed, where the first files
+ * the process opens would take their place
+ */
+func discardOutput(files []*os.File, null *os.File) []*os.File {
+	files = append([]*os.File{}, files...)
+	for n := 1; n <= 2 && n < len(files); n++ {
+		files[n] = null
+	}
+	return files
+}
+
+/*
+ * frame returns the Frame of the lines of a stream, read without the lock
+ * as lines are written while it is held
+ */
+func (i *Instance) frame(stream string) func() output.Frame {
+	return func() output.Frame {
+		return output.Frame{
+			Job:      i.JobID,
+			Instance: i.InstanceID,
+			Pid:      int(atomic.LoadInt32(&i.pid)),
+			Stream:   stream,
+		}
+	}
+}
+
+/*
+ * closeLogs writes out the partial lines being framed & closes the log files
+ * once the process's output has been copied
+ */
+func (i *Instance) closeLogs() {
+	for _, w := range i.framers {
+		w.Flush()
+	}
+	i.framers = nil
+	for _, f := range i.logs {
+		if f != nil {
+			f.Close()
+		}
+	}
+	i.logs = nil
+}
+
+/*
+ * ReopenLogs reopens the log files of the running process, which carries on
+ * writing to its pipes undisturbed
+ */
+func (i *Instance) ReopenLogs() error {
+	defer i.Mutex.RUnlock()
+	i.Mutex.RLock()
+	for _, f := range i.logs {
+		if f != nil {
+			if err := f.Reopen(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * lastOutput returns the final lines written by a process which died
+ * unexpectedly, nil if it exited with the expected code or was stopped by
+ * the user or taskmaster
+ */
+func (i *Instance) lastOutput(state *os.ProcessState) []string {
+	if i.Recent == nil || state == nil || i.Stopped || i.ExitReason != "" ||
+		state.ExitCode() == i.ExpectedExit {
+		return nil
+	}
+	return i.Recent.Since(i.recentMark, LASTOUTPUTLINES)
+}
+
+/*
+ * CapturedFiles returns taskmaster's ends of the pipes to the running
+ * process, to hand them over to the taskmaster it upgrades into
+ */
+func (i *Instance) CapturedFiles() (*os.File, []*os.File) {
+	defer i.Mutex.RUnlock()
+	i.Mutex.RLock()
+	return i.stdin, i.captured
+}
+
+/*
+ * ResumeCapture resumes copying the output of a process adopted during an
+ * upgrade from the pipes handed over by the previous taskmaster
+ */
+func (i *Instance) ResumeCapture(stdin *os.File, captured []*os.File) error {
+	defer i.Mutex.Unlock()
+	i.Mutex.Lock()
+	if i.Stdout == nil {
+		closeFiles(append(captured, stdin))
+		return nil
+	} else if err := i.openLogs(); err != nil {
+		closeFiles(append(captured, stdin))
+		return err
+	}
+	i.stdin, i.captured = stdin, captured
+	if i.TTY && len(captured) > 0 {
+		i.stdin, i.tty = captured[0], captured[0]
+	}
+	i.startCapture(nil)
+	return nil
+}
+
+/*
+ * Output returns the broadcaster copying stdout, or stderr if stderr is set,
+ * and the file it is written to, nil & empty if the stream is not captured
+ */
+func (i *Instance) Output(stderr bool) (*output.Broadcaster, string) {
+	n, b := 0, i.Stdout
+	if stderr && !i.TTY {
+		n, b = 1, i.Stderr
+	}
+	if n < len(i.LogFiles) {
+		return b, i.LogFiles[n]
+	}
+	return b, ""
+}
@@ -1,8 +1,10 @@
 package instance
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -10,6 +12,9 @@ import (
 
 	CFG "github.com/Travmatth/taskmaster/config"
 	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/output"
+	"github.com/Travmatth/taskmaster/proc"
+	"github.com/Travmatth/taskmaster/pty"
 	SIG "github.com/Travmatth/taskmaster/signals"
 )
 
@@ -42,6 +47,18 @@ const (
 	 * PROCSTARTFAIL signifies process could not start successfully
 	 */
 	PROCSTARTFAIL
+	/*
+	 * PROCSUCCEEDED signifies oneshot process ran to completion successfully
+	 */
+	PROCSUCCEEDED
+	/*
+	 * PROCFAILED signifies oneshot process exhausted its retries
+	 */
+	PROCFAILED
+	/*
+	 * PROCPAUSED signifies process frozen until it is resumed
+	 */
+	PROCPAUSED
 )
 
 const (
@@ -59,6 +76,21 @@ const (
 	RESTARTUNEXPECTED
 )
 
+/*
+ * LASTOUTPUTLINES is the number of final lines of output logged when a
+ * process dies unexpectedly
+ */
+const LASTOUTPUTLINES = 10
+
+/*
+ * StopStep is a signal sent while stopping the process, along with the time
+ * to wait for the process to exit before moving on to the next step
+ */
+type StopStep struct {
+	Signal os.Signal
+	Wait   time.Duration
+}
+
 /*
  * Instance struct manages the execution of one process
  */
@@ -73,6 +105,8 @@ type Instance struct {
 	MaxRestarts   int32
 	StopSignal    os.Signal
 	StopTimeout   int
+	StopSequence  []StopStep
+	TermSignal    os.Signal
 	EnvVars       []string
 	WorkingDir    string
 	Umask         int
@@ -88,6 +122,45 @@ type Instance struct {
 	Cfg           *CFG.JobConfig
 	Starting      bool
 	FinishedCh    chan struct{}
+	ProcStart     uint64
+	Adopted       bool
+	adoptPID      int
+	OnExit        []func(i *Instance)
+	Oneshot       bool
+	Retries       int
+	ListenFiles   []*os.File
+	ListenNames   []string
+	Watchdog      *Watchdog
+	MaxRuntime    time.Duration
+	ExitReason    string
+	reason        string
+	restartPID    int
+	freezer       string
+	unpaused      int
+	pausedAt      time.Time
+	pausedTotal   time.Duration
+	Interactive   bool
+	Stdout        *output.Broadcaster
+	Stderr        *output.Broadcaster
+	stdin         *os.File
+	captured      []*os.File
+	drained       []chan struct{}
+	waited        *os.Process
+	TTY           bool
+	TTYSize       pty.Size
+	tty           *os.File
+	LogFiles      []string
+	Rotation      output.Rotation
+	Framing       output.Framing
+	logs          []*output.RotatingFile
+	framers       []*output.LineWriter
+	Lines         []*output.LineWriter
+	Recent        *output.Ring
+	Limiter       *output.Limiter
+	limited       []*output.LimitWriter
+	LastOutput    []string
+	recentMark    int
+	pid           int32
 }
 
 /*
@@ -104,6 +177,7 @@ func (i *Instance) StartInstance(wait bool) {
 	}
 	i.Starting = true
 	i.Stopped = false
+	i.Retries = 0
 	i.Mutex.Unlock()
 	var cond *sync.Cond
 	done := false
@@ -149,12 +223,17 @@ func (i *Instance) StartInstance(wait bool) {
 func (i *Instance) shouldRerunInstance() bool {
 	switch {
 	case i.Stopped:
-		Log.Info(i, ": stopped by user, not restarting")
+		Log.Info(i, ": stopped by user, not restarting", Event("stopped"))
 		return false
+	case i.breached():
+		return true
+	case i.Oneshot:
+		return i.shouldRetryOneshot()
 	case i.Process == nil || i.Status == PROCSTARTFAIL:
 		return false
 	case i.RestartPolicy == RESTARTNEVER:
-		Log.Info(i, ": restart policy specifies do not restart")
+		Log.Info(i, ": restart policy specifies do not restart",
+			Event("not_restarting"))
 		return false
 	case i.RestartPolicy == RESTARTUNEXPECTED:
 		end := true
@@ -162,7 +241,8 @@ func (i *Instance) shouldRerunInstance() bool {
 		exit := i.State.ExitCode()
 		if exit != i.ExpectedExit {
 			message := ": Encountered unexpected exit code"
-			Log.Info(i, message, exit, ", restarting")
+			Log.Info(i, message, exit, ", restarting",
+				Event("restarting", "exit_code", exit))
 			end = false
 		}
 		i.Mutex.RUnlock()
@@ -173,6 +253,39 @@ func (i *Instance) shouldRerunInstance() bool {
 	return true
 }
 
+/*
+ * shouldRetryOneshot marks a oneshot process which exited with the expected
+ * code as succeeded, otherwise as failed, retrying it until maxRestarts
+ * retries have been made
+ */
+func (i *Instance) shouldRetryOneshot() bool {
+	defer i.Mutex.Unlock()
+	i.Mutex.Lock()
+	if i.Process == nil || i.Status == PROCSTARTFAIL {
+		i.ChangeStatus(PROCFAILED)
+		Log.Info(i, ": oneshot failed to start", Event("oneshot_failed"))
+		return false
+	}
+	exit := i.State.ExitCode()
+	if exit == i.ExpectedExit {
+		i.ChangeStatus(PROCSUCCEEDED)
+		Log.Info(i, ": oneshot succeeded with exit code", exit,
+			Event("oneshot_succeeded", "exit_code", exit))
+		return false
+	}
+	i.ChangeStatus(PROCFAILED)
+	if i.Retries >= int(i.MaxRestarts) {
+		Log.Info(i, ": oneshot failed with exit code", exit,
+			Event("oneshot_failed", "exit_code", exit))
+		return false
+	}
+	i.Retries++
+	message := ": oneshot failed with exit code"
+	Log.Info(i, message, exit, ", retry", i.Retries, "of", i.MaxRestarts,
+		Event("oneshot_retrying", "exit_code", exit, "retry", i.Retries))
+	return true
+}
+
 /*
  * Run launches the process and monitors the start, restarting if start has
  * failed on successful start, waits for process to complete and sends a
@@ -182,25 +295,28 @@ func (i *Instance) Run(callback func()) {
 	defer i.Mutex.Unlock()
 	i.Mutex.Lock()
 	if i.PIDExists() {
-		Log.Info(i, ": already running")
+		Log.Info(i, ": already running", Event("already_running"))
 		callback()
 		return
 	}
-	i.StartTime = time.Now()
+	if i.adoptPID == 0 {
+		i.StartTime = time.Now()
+	}
 	atomic.StoreInt32(i.Restarts, 0)
 	var once sync.Once
 	for !i.Stopped {
 		i.ChangeStatus(PROCSTART)
 		atomic.AddInt32(i.Restarts, 1)
-		if err := i.CreateJob(); err != nil {
+		if err := i.launch(); err != nil {
 			restarts := atomic.LoadInt32(i.Restarts)
 			if restarts > i.MaxRestarts {
 				errStr := fmt.Sprintf("failed to start with error: %s", err)
-				Log.Info(i, ": Creation failed:", errStr)
+				Log.Info(i, ": Creation failed:", errStr, Event("start_failed"))
 				callback()
 				break
 			} else {
-				Log.Info(i, ": failed to start with error:", err)
+				Log.Info(i, ": failed to start with error:", err,
+					Event("start_retrying"))
 				i.ChangeStatus(PROCBACKOFF)
 				continue
 			}
@@ -222,8 +338,10 @@ func (i *Instance) manageRunningProgram(callbackWrapper func()) {
 	end := time.Now().Add(time.Duration(i.StartCheckup) * time.Second)
 	monitorExited := int32(0)
 	programExited := int32(0)
-	if i.StartCheckup <= 0 {
-		Log.Info(i, ": Successfully Started with no start checkup")
+	i.pausedTotal = 0
+	if i.StartCheckup <= 0 || i.Oneshot {
+		Log.Info(i, ": Successfully Started with no start checkup",
+			Event("started"))
 		i.ChangeStatus(PROCRUNNING)
 		go callbackWrapper()
 	} else {
@@ -231,10 +349,19 @@ func (i *Instance) manageRunningProgram(callbackWrapper func()) {
 			i.startCheckup(callbackWrapper, end, &monitorExited, &programExited)
 		}()
 	}
+	exited := make(chan struct{})
+	if i.Watchdog != nil {
+		go i.watchdog(i.Process.Pid, exited)
+	}
+	if i.MaxRuntime > 0 {
+		go i.limitRuntime(exited)
+	}
 	i.Mutex.Unlock()
 	i.WaitForExit()
+	close(exited)
 	atomic.StoreInt32(&programExited, 1)
-	for i.StartCheckup > 0 && atomic.LoadInt32(&monitorExited) == 0 {
+	for i.StartCheckup > 0 && !i.Oneshot &&
+		atomic.LoadInt32(&monitorExited) == 0 {
 		time.Sleep(time.Duration(10) * time.Millisecond)
 	}
 }
@@ -256,46 +383,252 @@ func (i *Instance) shouldRestartInstance(callback func()) bool {
 	if atomic.LoadInt32(i.Restarts) >= i.MaxRestarts {
 		i.ChangeStatus(PROCSTARTFAIL)
 		message := fmt.Sprintf("Failed to start maximum retries reached")
-		Log.Info(i, ": Creation failed:", message)
+		Log.Info(i, ": Creation failed:", message, Event("start_failed"))
 		callback()
 		return false
 	}
-	Log.Info(i, ": Start failed, restarting")
+	Log.Info(i, ": Start failed, restarting", Event("start_retrying"))
 	return true
 }
 
+/*
+ * Adopt re-attaches the instance to a process launched by a previous
+ * taskmaster, monitoring it in place of launching a new process
+ */
+func (i *Instance) Adopt(pid int, procStart uint64, startTime time.Time) {
+	i.Mutex.Lock()
+	i.adoptPID = pid
+	i.ProcStart = procStart
+	i.StartTime = startTime
+	i.Mutex.Unlock()
+	i.StartInstance(false)
+}
+
+/*
+ * launch creates the process, or takes over the process given to Adopt
+ */
+func (i *Instance) launch() error {
+	if i.adoptPID == 0 {
+		return i.CreateJob()
+	}
+	pid := i.adoptPID
+	i.adoptPID = 0
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	i.Process = process
+	atomic.StoreInt32(&i.pid, int32(pid))
+	Log.Info(i, ": adopted running process", pid, Event("adopted"))
+	if i.Stdout != nil && i.captured == nil {
+		// the pipes it writes to were only handed over by an upgrade
+		Log.Warning(i, ": output of adopted process", pid,
+			"is no longer captured, restart it to capture its output",
+			Event("capture_lost", "pid", pid))
+	}
+	i.State = nil
+	i.Adopted = true
+	return nil
+}
+
 /*
  * CreateJob creates & launches process
  */
 func (i *Instance) CreateJob() error {
 	defaultUmask := syscall.Umask(i.Umask)
 	defer syscall.Umask(defaultUmask)
-	process, err := os.StartProcess(i.Args[0], i.Args, &os.ProcAttr{
+	args, env, files := i.Args, i.EnvVars, i.Redirections
+	if len(i.ListenFiles) != 0 {
+		args, env, files = i.socketActivation()
+	}
+	// each instance leads its own process group so that descendants
+	// can be traced back to it once they are reparented, so that pause &
+	// signal --group reach its children, & so that a Ctrl-C at the terminal
+	// is left to taskmaster, which stops jobs with their stop sequence
+	sys := &syscall.SysProcAttr{Setpgid: true}
+	var pipes []*os.File
+	var err error
+	if i.TTY {
+		// a new session, led by the process, makes the terminal its
+		// controlling terminal
+		sys = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+		pipes, err = i.openTTY()
+	} else if i.Stdout != nil {
+		pipes, err = i.openPipes()
+	}
+	if err == nil && pipes != nil {
+		err = i.openLogs()
+	}
+	if err != nil {
+		if pipes != nil {
+			i.closePipes(pipes)
+		}
+		return err
+	} else if pipes != nil {
+		files = append([]*os.File{}, files...)
+		for n, f := range pipes {
+			if f != nil {
+				files[n] = f
+			}
+		}
+	} else {
+		null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer null.Close()
+		files = discardOutput(files, null)
+	}
+	Log.Debug(i, ": launching", strings.Join(args, " "), Event("launching"))
+	process, err := os.StartProcess(args[0], args, &os.ProcAttr{
 		Dir:   i.WorkingDir,
-		Env:   i.EnvVars,
-		Files: i.Redirections,
+		Env:   env,
+		Files: files,
+		Sys:   sys,
 	})
 	if err != nil {
+		if pipes != nil {
+			i.closePipes(pipes)
+		}
 		return err
 	}
+	// the pid is set before output is copied, as framed lines include it
+	atomic.StoreInt32(&i.pid, int32(process.Pid))
+	if pipes != nil {
+		i.startCapture(pipes)
+	}
 	i.Process = process
+	i.Adopted = false
+	Log.Debug(i, ": launched process", process.Pid, Event("launched"))
+	i.ProcStart = 0
+	if stat, err := proc.ReadStat(process.Pid); err == nil {
+		i.ProcStart = stat.StartTime
+	}
 	return nil
 }
 
+/*
+ * socketActivation passes the listening sockets as descriptors 3 onwards,
+ * following the systemd protocol, see `man 3 sd_listen_fds`. LISTEN_PID
+ * must hold the pid of the program, which is unknown before it is started,
+ * so the program is launched through a shell which sets it before exec
+ */
+func (i *Instance) socketActivation() ([]string, []string, []*os.File) {
+	env := i.EnvVars
+	if len(env) == 0 {
+		env = os.Environ()
+	}
+	env = append(env[:len(env):len(env)],
+		fmt.Sprintf("LISTEN_FDS=%d", len(i.ListenFiles)),
+		"LISTEN_FDNAMES="+strings.Join(i.ListenNames, ":"))
+	files := append(i.Redirections[:len(i.Redirections):len(i.Redirections)],
+		i.ListenFiles...)
+	script := `LISTEN_PID=$$; export LISTEN_PID; exec "$0" "$@"`
+	args := append([]string{"/bin/sh", "-c", script}, i.Args...)
+	return args, env, files
+}
+
 /*
  * WaitForExit waits for os.Process exit and saves returned ProcessState
  */
 func (i *Instance) WaitForExit() {
 	State, err := i.Process.Wait()
+	if err != nil && i.Adopted && errors.Is(err, syscall.ECHILD) {
+		// processes adopted from a previous taskmaster are not our children
+		i.pollForExit()
+		err = nil
+	}
+	// the process has exited even while a descendant keeps its output open
+	i.Mutex.Lock()
+	i.waited = i.Process
+	i.Mutex.Unlock()
+	i.drain()
 	if err != nil {
-		Log.Info(i, ": error waiting for exit: ", err)
+		Log.Info(i, ": error waiting for exit: ", err, Event("wait_failed"))
 	} else if State != nil {
-		Log.Info(i, ": exited with status:", State)
+		Log.Info(i, ": exited with status:", State,
+			Event("exited", exitFields(State)...))
 	}
 	i.Mutex.Lock()
 	i.State = State
 	i.StopTime = time.Now()
+	i.ExitReason, i.reason = i.reason, ""
+	i.LastOutput = i.lastOutput(State)
+	if i.Status == PROCPAUSED {
+		i.unpause()
+	}
+	i.TermSignal = nil
+	if State != nil {
+		status, ok := State.Sys().(syscall.WaitStatus)
+		if ok && status.Signaled() {
+			i.TermSignal = status.Signal()
+		}
+	}
 	i.Mutex.Unlock()
+	if len(i.LastOutput) != 0 {
+		Log.Info(i, ": last output:", fmt.Sprintf("%q", strings.Join(i.LastOutput, "\n")),
+			Event("last_output", "output", i.LastOutput))
+	}
+	for _, hook := range i.OnExit {
+		hook(i)
+	}
+}
+
+/*
+ * pollForExit waits for an adopted process which cannot be waited on,
+ * comparing its start time to detect the pid being reused
+ */
+func (i *Instance) pollForExit() {
+	for {
+		stat, err := proc.ReadStat(i.Process.Pid)
+		if err != nil || stat.State == 'Z' || stat.StartTime != i.ProcStart {
+			Log.Info(i, ": adopted process", i.Process.Pid, "exited",
+				Event("exited"))
+			return
+		}
+		time.Sleep(time.Duration(500) * time.Millisecond)
+	}
+}
+
+/*
+ * ExitCode returns the exit code of the last process, or -1 if it has not
+ * exited or was terminated by a signal
+ */
+func (i *Instance) ExitCode() int {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	return i.State.ExitCode()
+}
+
+/*
+ * WaitForIdle blocks until the instance has finished managing its process,
+ * after which it may be started again
+ */
+func (i *Instance) WaitForIdle() {
+	for !i.Idle() {
+		time.Sleep(time.Duration(10) * time.Millisecond)
+	}
+}
+
+/*
+ * Idle reports whether the instance is not managing a process
+ */
+func (i *Instance) Idle() bool {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	return !i.Starting
+}
+
+/*
+ * Running reports whether the instance currently has a live process
+ */
+func (i *Instance) Running() bool {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	return i.Process != nil && (i.Status == PROCSTART ||
+		i.Status == PROCRUNNING ||
+		i.Status == PROCSTOPPING ||
+		i.Status == PROCPAUSED)
 }
 
 /*
@@ -314,13 +647,55 @@ func (i *Instance) PIDExists() bool {
 	return false
 }
 
+/*
+ * PID returns the pid of the last process launched, or 0 if there is none
+ */
+func (i *Instance) PID() int {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	if i.Process == nil {
+		return 0
+	}
+	return i.Process.Pid
+}
+
+/*
+ * Signal sends the given signal to the running process
+ */
+func (i *Instance) Signal(sig os.Signal) error {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	if i.Process == nil {
+		return errors.New("process not running")
+	}
+	return i.Process.Signal(sig)
+}
+
+/*
+ * SignalGroup sends the given signal to the process group of the running
+ * process, reaching any children it has launched
+ */
+func (i *Instance) SignalGroup(sig os.Signal) error {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	if i.Process == nil {
+		return errors.New("process not running")
+	}
+	num, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %v", sig)
+	}
+	return syscall.Kill(-i.Process.Pid, num)
+}
+
 /*
  * startCheckup checks that the process has successfully started after the
  * specified start checkup time
  */
 func (i *Instance) startCheckup(callback func(), end time.Time,
 	monitor *int32, program *int32) {
-	for time.Now().Before(end) && atomic.LoadInt32(program) == 0 {
+	for (time.Now().Before(end.Add(i.pausedTime())) || i.Paused()) &&
+		atomic.LoadInt32(program) == 0 {
 		time.Sleep(time.Duration(100) * time.Millisecond)
 	}
 	atomic.StoreInt32(monitor, 1)
@@ -328,12 +703,14 @@ func (i *Instance) startCheckup(callback func(), end time.Time,
 	i.Mutex.Lock()
 	progState := atomic.LoadInt32(program)
 	if progState == 0 && i.Status == PROCSTART {
-		Log.Info(i, ": Successfully Started after", i.StartCheckup, "second(s)")
+		Log.Info(i, ": Successfully Started after", i.StartCheckup, "second(s)",
+			Event("started"))
 		i.Status = PROCRUNNING
 		callback()
 	} else {
 		message := ": monitor failed, program exit: "
-		Log.Info(i, message, progState, " with job status", i.Status)
+		Log.Info(i, message, progState, " with job status", i.Status,
+			Event("start_check_failed"))
 	}
 }
 
@@ -358,7 +735,8 @@ func (i *Instance) StopInstance(wait bool) {
 			i.Mutex.RLock()
 			if i.Status != PROCSTART &&
 				i.Status != PROCRUNNING &&
-				i.Status != PROCSTOPPING {
+				i.Status != PROCSTOPPING &&
+				i.Status != PROCPAUSED {
 				i.Mutex.RUnlock()
 				break
 			}
@@ -369,26 +747,79 @@ func (i *Instance) StopInstance(wait bool) {
 }
 
 /*
- * stopTimeout signals the process to stop using the specified signal
- * if wait() is not called then a SIGKILL is sent to the process
+ * stopTimeout walks the stop sequence, sending each signal and waiting for
+ * the process to exit before escalating to the next one. If the process is
+ * still running after the final step a SIGKILL is sent to the process
  */
 func (i *Instance) stopTimeout() {
-	i.Mutex.RLock()
-	if i.Process != nil {
-		Log.Info(i, ": Sending Signal", i.StopSignal)
-		i.Process.Signal(i.StopSignal)
+	if !i.Running() {
+		return
 	}
+	sequence := i.stopSequence()
+	i.Mutex.RLock()
+	process := i.Process
 	i.Mutex.RUnlock()
-	select {
-	case <-time.After(time.Duration(i.StopTimeout) * time.Second):
-		message := ": did not stop after timeout of "
-		Log.Info(i, message, i.StopTimeout, "seconds SIGKILL issued")
-		if i.Process != nil {
-			i.Process.Signal(SIG.Signals["SIGKILL"])
-			<-i.FinishedCh
+	for n, step := range sequence {
+		// only the process being stopped is signalled, never one relaunched
+		// in its place
+		if i.exited(process) {
+			return
+		}
+		Log.Info(i, ": Sending Signal", step.Signal,
+			Event("signal_sent", "signal", signalName(step.Signal)))
+		process.Signal(step.Signal)
+		// a paused process cannot act on the signal until it is continued
+		i.Mutex.Lock()
+		if i.Status == PROCPAUSED {
+			i.resume()
+		}
+		i.Mutex.Unlock()
+		select {
+		case <-time.After(step.Wait):
+			// the exit is missed on FinishedCh if it came while signalling
+			if i.exited(process) {
+				return
+			} else if n+1 < len(sequence) {
+				message := ": did not stop after"
+				Log.Info(i, message, step.Wait, "escalating to", sequence[n+1].Signal,
+					Event("stop_escalated", "signal", signalName(sequence[n+1].Signal)))
+			}
+		case <-i.FinishedCh:
+			return
 		}
-	case <-i.FinishedCh:
 	}
+	if i.exited(process) {
+		return
+	}
+	message := ": did not stop after timeout of "
+	wait := sequence[len(sequence)-1].Wait.Seconds()
+	Log.Info(i, message, wait, "seconds SIGKILL issued",
+		Event("killed", "signal", "SIGKILL"))
+	process.Signal(SIG.Signals["SIGKILL"])
+	<-i.FinishedCh
+}
+
+/*
+ * exited reports whether the given process has exited, or been replaced.
+ * State is not consulted as it still holds the previous run's exit until
+ * the relaunched process exits in turn
+ */
+func (i *Instance) exited(process *os.Process) bool {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	return i.Process != process || i.waited == process
+}
+
+/*
+ * stopSequence returns the configured stop sequence, falling back to the
+ * stopSignal and stopTimeout pair when no sequence was given
+ */
+func (i *Instance) stopSequence() []StopStep {
+	if len(i.StopSequence) != 0 {
+		return i.StopSequence
+	}
+	wait := time.Duration(i.StopTimeout) * time.Second
+	return []StopStep{{Signal: i.StopSignal, Wait: wait}}
 }
 
 /*
@@ -413,6 +844,12 @@ func (i *Instance) GetStatus() string {
 		status = "stopping"
 	case PROCSTARTFAIL:
 		status = "start failed"
+	case PROCSUCCEEDED:
+		status = "succeeded"
+	case PROCFAILED:
+		status = "failed"
+	case PROCPAUSED:
+		status = "paused"
 	}
 	return status
 }
@@ -423,3 +860,37 @@ func (i *Instance) GetStatus() string {
 func (i *Instance) String() string {
 	return fmt.Sprintf("Job %d Instance %d", i.JobID, i.InstanceID)
 }
+
+/*
+ * LogFields identifies the instance & its last process in structured logs.
+ * It takes no lock as messages are logged while holding it
+ */
+func (i *Instance) LogFields() Fields {
+	fields := Fields{"job": i.JobID, "instance": i.InstanceID}
+	if pid := atomic.LoadInt32(&i.pid); pid != 0 {
+		fields["pid"] = pid
+	}
+	return fields
+}
+
+/*
+ * exitFields returns the exit code of a process, and the signal which
+ * terminated it if any, as pairs of keys & values
+ */
+func exitFields(state *os.ProcessState) []interface{} {
+	fields := []interface{}{"exit_code", state.ExitCode()}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		fields = append(fields, "signal", SIG.Name(status.Signal()))
+	}
+	return fields
+}
+
+/*
+ * signalName returns the name of a signal for structured logs
+ */
+func signalName(sig os.Signal) string {
+	if s, ok := sig.(syscall.Signal); ok {
+		return SIG.Name(s)
+	}
+	return sig.String()
+}
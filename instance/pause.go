@@ -0,0 +1,147 @@
+package instance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/proc"
+)
+
+/*
+ * Pause freezes the running process along with its process group, using the
+ * cgroup freezer when the process runs in a cgroup of its own
+ */
+func (i *Instance) Pause() error {
+	defer i.Mutex.Unlock()
+	i.Mutex.Lock()
+	if i.Process == nil || (i.Status != PROCSTART && i.Status != PROCRUNNING) {
+		return fmt.Errorf("%v is not running", i)
+	}
+	i.freezer = freezerFile(i.Process.Pid)
+	if err := i.freeze(true); err != nil {
+		return err
+	}
+	i.unpaused = i.Status
+	i.pausedAt = time.Now()
+	i.ChangeStatus(PROCPAUSED)
+	Log.Info(i, ": paused", Event("paused"))
+	return nil
+}
+
+/*
+ * Resume continues a paused process
+ */
+func (i *Instance) Resume() error {
+	defer i.Mutex.Unlock()
+	i.Mutex.Lock()
+	if i.Status != PROCPAUSED {
+		return fmt.Errorf("%v is not paused", i)
+	}
+	return i.resume()
+}
+
+/*
+ * resume continues the process and restores the status it had before it was
+ * paused, the caller must hold the lock
+ */
+func (i *Instance) resume() error {
+	if err := i.freeze(false); err != nil {
+		return err
+	}
+	i.unpause()
+	Log.Info(i, ": resumed", Event("resumed"))
+	return nil
+}
+
+/*
+ * unpause restores the status the process had before it was paused, the
+ * caller must hold the lock
+ */
+func (i *Instance) unpause() {
+	i.pausedTotal += time.Since(i.pausedAt)
+	i.pausedAt = time.Time{}
+	i.ChangeStatus(i.unpaused)
+}
+
+/*
+ * Paused reports whether the process is currently paused
+ */
+func (i *Instance) Paused() bool {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	return i.Status == PROCPAUSED
+}
+
+/*
+ * pausedTime returns how long the current process has spent paused, so that
+ * time paused is not counted against the process by start checks or limits
+ */
+func (i *Instance) pausedTime() time.Duration {
+	i.Mutex.RLock()
+	defer i.Mutex.RUnlock()
+	if i.Status == PROCPAUSED {
+		return i.pausedTotal + time.Since(i.pausedAt)
+	}
+	return i.pausedTotal
+}
+
+/*
+ * freeze stops or continues the process, writing to the cgroup freezer if
+ * one was found when pausing, otherwise sending SIGSTOP or SIGCONT to the
+ * process group
+ */
+func (i *Instance) freeze(frozen bool) error {
+	if i.freezer != "" {
+		value := map[bool]string{true: "1", false: "0"}
+		if filepath.Base(i.freezer) == "freezer.state" {
+			value = map[bool]string{true: "FROZEN", false: "THAWED"}
+		}
+		return ioutil.WriteFile(i.freezer, []byte(value[frozen]), 0644)
+	}
+	sig := syscall.SIGCONT
+	if frozen {
+		sig = syscall.SIGSTOP
+	}
+	pid := i.Process.Pid
+	if err := syscall.Kill(-pid, sig); err != nil {
+		return syscall.Kill(pid, sig)
+	}
+	return nil
+}
+
+/*
+ * freezerFile returns the freezer control file of the process's cgroup, or
+ * an empty string if the process shares taskmaster's cgroup, as freezing it
+ * would freeze taskmaster as well
+ */
+func freezerFile(pid int) string {
+	own, err := proc.ReadCgroups(os.Getpid())
+	if err != nil {
+		return ""
+	}
+	cgroups, err := proc.ReadCgroups(pid)
+	if err != nil {
+		return ""
+	}
+	files := []struct{ controller, dir, file string }{
+		{"freezer", "freezer", "freezer.state"},
+		{"", "", "cgroup.freeze"},
+		{"", "unified", "cgroup.freeze"},
+	}
+	for _, f := range files {
+		path, ok := cgroups[f.controller]
+		if !ok || path == own[f.controller] {
+			continue
+		}
+		file := filepath.Join(proc.CgroupRoot, f.dir, path, f.file)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/pty"
+)
+
+/*
+ * openTTY allocates a pseudo-terminal for the process, returning the slave
+ * side to be given to the process as its standard input, output & error.
+ * Taskmaster keeps the master side to write input & capture output
+ */
+func (i *Instance) openTTY() ([]*os.File, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	} else if err = pty.SetSize(master, i.TTYSize); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+	i.stdin, i.captured, i.tty = master, []*os.File{master}, master
+	return []*os.File{slave, slave, slave}, nil
+}
+
+/*
+ * Resize sets the window size of the process's terminal, which is kept for
+ * the terminals of later processes
+ */
+func (i *Instance) Resize(size pty.Size) error {
+	defer i.Mutex.Unlock()
+	i.Mutex.Lock()
+	if !i.TTY {
+		return fmt.Errorf("%v does not run on a terminal", i)
+	}
+	i.TTYSize = size
+	if i.tty != nil {
+		if err := pty.SetSize(i.tty, size); err != nil {
+			return err
+		}
+	}
+	Log.Info(i, ": terminal resized to", size,
+		Event("resized", "size", size.String()))
+	return nil
+}
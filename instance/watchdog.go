@@ -0,0 +1,173 @@
+package instance
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/proc"
+)
+
+/*
+ * Watchdog holds the resource limits a running process is sampled against,
+ * a zero limit is not checked
+ */
+type Watchdog struct {
+	MaxRSS      uint64
+	MaxCPU      float64
+	CPUDuration time.Duration
+	MaxFiles    int
+	Interval    time.Duration
+}
+
+/*
+ * sampler tracks the cpu time used by a process between samples
+ */
+type sampler struct {
+	watchdog *Watchdog
+	pid      int
+	cpu      uint64
+	sampled  time.Time
+	busy     time.Time
+}
+
+/*
+ * check samples the process from /proc, returning a description of the
+ * limit breached, or an empty string if the process is within its limits
+ */
+func (s *sampler) check(now time.Time) (string, error) {
+	w := s.watchdog
+	if w.MaxRSS != 0 {
+		rss, err := proc.ReadRSS(s.pid)
+		if err != nil {
+			return "", err
+		} else if rss > w.MaxRSS {
+			message := "memory limit exceeded: rss %dMB > %dMB"
+			return fmt.Sprintf(message, rss>>20, w.MaxRSS>>20), nil
+		}
+	}
+	if w.MaxFiles != 0 {
+		files, err := proc.OpenFiles(s.pid)
+		if err != nil {
+			return "", err
+		} else if files > w.MaxFiles {
+			message := "open files limit exceeded: %d > %d"
+			return fmt.Sprintf(message, files, w.MaxFiles), nil
+		}
+	}
+	if w.MaxCPU != 0 {
+		stat, err := proc.ReadStat(s.pid)
+		if err != nil {
+			return "", err
+		}
+		cpu, prev, sampled := stat.Utime+stat.Stime, s.cpu, s.sampled
+		s.cpu, s.sampled = cpu, now
+		if sampled.IsZero() {
+			return "", nil
+		}
+		used := float64(cpu-prev) / proc.ClockTicks
+		percent := 100 * used / now.Sub(sampled).Seconds()
+		if percent <= w.MaxCPU {
+			s.busy = time.Time{}
+		} else if s.busy.IsZero() {
+			s.busy = sampled
+		}
+		if !s.busy.IsZero() && now.Sub(s.busy) >= w.CPUDuration {
+			message := "cpu limit exceeded: over %.0f%% for %v"
+			return fmt.Sprintf(message, w.MaxCPU, w.CPUDuration), nil
+		}
+	}
+	return "", nil
+}
+
+/*
+ * watchdog samples the process every interval until it exits, restarting
+ * the instance when it breaches one of its limits
+ */
+func (i *Instance) watchdog(pid int, exited chan struct{}) {
+	s := sampler{watchdog: i.Watchdog, pid: pid}
+	for {
+		select {
+		case <-exited:
+			return
+		case <-time.After(i.Watchdog.Interval):
+		}
+		if i.Paused() {
+			// a paused process is not sampled, nor is its cpu time
+			// counted across the pause
+			s.sampled, s.busy = time.Time{}, time.Time{}
+			continue
+		}
+		if reason, err := s.check(time.Now()); err != nil {
+			// the process exited between samples
+			return
+		} else if reason != "" {
+			i.restartFor(pid, reason)
+			return
+		}
+	}
+}
+
+/*
+ * restartFor stops the process through the normal stop path, recording why
+ * as the exit reason, and restarts it regardless of the restart policy
+ */
+func (i *Instance) restartFor(pid int, reason string) {
+	i.Mutex.Lock()
+	if i.Stopped {
+		i.Mutex.Unlock()
+		return
+	}
+	i.reason = reason
+	i.restartPID = pid
+	i.Mutex.Unlock()
+	Log.Info(i, ":", reason, ", restarting",
+		Event("limit_exceeded", "reason", reason))
+	i.stopTimeout()
+}
+
+/*
+ * breached reports whether the last process run was stopped for breaching
+ * its limits, and so should be restarted. A breach by a process which was
+ * retried in its place, such as one stopped while starting, is forgotten
+ */
+func (i *Instance) breached() bool {
+	defer i.Mutex.Unlock()
+	i.Mutex.Lock()
+	pid := i.restartPID
+	i.restartPID = 0
+	return pid != 0 && i.Process != nil && i.Process.Pid == pid
+}
+
+/*
+ * limitRuntime stops the process through the normal stop path once it has
+ * run for MaxRuntime, recording the exit as a timeout and leaving the
+ * restart policy to decide whether it runs again
+ */
+func (i *Instance) limitRuntime(exited chan struct{}) {
+	start := time.Now()
+	for {
+		// time spent paused does not count towards the runtime
+		remaining := i.MaxRuntime + i.pausedTime() - time.Since(start)
+		if remaining <= 0 && !i.Paused() {
+			break
+		} else if remaining < time.Duration(100)*time.Millisecond {
+			remaining = time.Duration(100) * time.Millisecond
+		}
+		select {
+		case <-exited:
+			return
+		case <-time.After(remaining):
+		}
+	}
+	i.Mutex.Lock()
+	if i.Stopped {
+		i.Mutex.Unlock()
+		return
+	}
+	i.reason = "timeout"
+	i.Mutex.Unlock()
+	Log.Info(i, ": exceeded maxRuntime of", i.MaxRuntime, ", stopping",
+		Event("timeout", "max_runtime", i.MaxRuntime.String()))
+	i.stopTimeout()
+}
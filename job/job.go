@@ -2,31 +2,262 @@ package job
 
 import (
 	"fmt"
+	"os"
+	"sync"
+	"time"
 
 	CFG "github.com/Travmatth/taskmaster/config"
 	INST "github.com/Travmatth/taskmaster/instance"
+	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/pty"
 )
 
 type Job struct {
-	ID        int
-	Instances []*INST.Instance
-	Pool      int
-	Cfg       *CFG.JobConfig
-	AtLaunch  bool
+	ID           int
+	Instances    []*INST.Instance
+	Pool         int
+	Cfg          *CFG.JobConfig
+	AtLaunch     bool
+	Scheduler    *Scheduler
+	Oneshot      bool
+	DependsOn    []int
+	Dependencies []*Job
+	Sockets      []*Socket
+	Lazy         bool
+	Watcher      *Watcher
+	cancel       chan struct{}
+	activation   chan struct{}
+	lock         sync.Mutex
 }
 
 func (j *Job) Start(wait bool) {
+	if len(j.Dependencies) == 0 {
+		j.start(wait)
+	} else if wait {
+		j.startAfterDependencies(wait)
+	} else {
+		go j.startAfterDependencies(wait)
+	}
+}
+
+func (j *Job) start(wait bool) {
+	if err := j.listen(); err != nil {
+		Log.Info(j, ": unable to listen:", err)
+		return
+	}
+	if j.Watcher != nil {
+		j.enableWatch()
+	}
+	if j.Scheduler != nil {
+		j.enableSchedule()
+		return
+	}
+	if j.Lazy {
+		j.lock.Lock()
+		if j.activation == nil {
+			j.activation = make(chan struct{})
+			Log.Info(j, ": waiting for a connection to start")
+			go j.activateOnConnection(j.activation)
+		}
+		j.lock.Unlock()
+		return
+	}
 	for _, instance := range j.Instances {
 		instance.StartInstance(wait)
 	}
 }
 
 func (j *Job) Stop(wait bool) {
+	j.lock.Lock()
+	if j.cancel != nil {
+		close(j.cancel)
+		j.cancel = nil
+	}
+	if j.activation != nil {
+		close(j.activation)
+		j.activation = nil
+	}
+	j.lock.Unlock()
+	if j.Scheduler != nil {
+		j.disableSchedule()
+	}
+	if j.Watcher != nil {
+		j.disableWatch()
+	}
 	for _, instance := range j.Instances {
 		instance.StopInstance(wait)
 	}
 }
 
-func (j Job) String() string {
+/*
+ * startAfterDependencies waits for every job this job depends on to run to
+ * completion successfully before starting it, giving up if one fails or the
+ * job is stopped in the meantime
+ */
+func (j *Job) startAfterDependencies(wait bool) {
+	j.lock.Lock()
+	if j.cancel != nil {
+		j.lock.Unlock()
+		return
+	}
+	cancel := make(chan struct{})
+	j.cancel = cancel
+	j.lock.Unlock()
+	defer func() {
+		j.lock.Lock()
+		if j.cancel == cancel {
+			j.cancel = nil
+		}
+		j.lock.Unlock()
+	}()
+	for _, dep := range j.Dependencies {
+		if !dep.Succeeded() {
+			Log.Info(j, ": waiting for", dep, "to complete")
+		}
+		for !dep.Succeeded() {
+			if dep.Failed() {
+				Log.Info(j, ": not starting,", dep, "failed")
+				return
+			}
+			select {
+			case <-cancel:
+				return
+			case <-time.After(time.Duration(100) * time.Millisecond):
+			}
+		}
+	}
+	j.start(wait)
+}
+
+/*
+ * Pause pauses the given instance of the job, or every running instance if
+ * instance is -1
+ */
+func (j *Job) Pause(instance int) error {
+	return j.forInstances(instance, (*INST.Instance).Pause)
+}
+
+/*
+ * Resume resumes the given instance of the job, or every paused instance if
+ * instance is -1
+ */
+func (j *Job) Resume(instance int) error {
+	return j.forInstances(instance, (*INST.Instance).Resume)
+}
+
+/*
+ * Signal sends the signal to the given running instance of the job, or to
+ * every running instance if instance is -1, optionally to the process group
+ */
+func (j *Job) Signal(instance int, sig os.Signal, group bool) error {
+	return j.forInstances(instance, func(inst *INST.Instance) error {
+		if !inst.Running() {
+			return fmt.Errorf("%v is not running", inst)
+		} else if group {
+			Log.Info(inst, ": Sending Signal", sig, "to process group")
+			return inst.SignalGroup(sig)
+		}
+		Log.Info(inst, ": Sending Signal", sig)
+		return inst.Signal(sig)
+	})
+}
+
+/*
+ * Resize sets the terminal window size of the given instance of the job, or
+ * of every instance if instance is -1
+ */
+func (j *Job) Resize(instance int, size pty.Size) error {
+	return j.forInstances(instance, func(inst *INST.Instance) error {
+		return inst.Resize(size)
+	})
+}
+
+/*
+ * forInstances applies f to the given instance, or to every instance if
+ * instance is -1, skipping instances f does not apply to
+ */
+func (j *Job) forInstances(instance int, f func(*INST.Instance) error) error {
+	if instance >= len(j.Instances) {
+		return fmt.Errorf("%v has no instance %d", j, instance)
+	} else if instance >= 0 {
+		return f(j.Instances[instance])
+	}
+	var err error
+	applied := false
+	for _, inst := range j.Instances {
+		if e := f(inst); e == nil {
+			applied = true
+		} else if err == nil {
+			err = e
+		}
+	}
+	if applied {
+		return nil
+	}
+	return err
+}
+
+/*
+ * idle reports whether none of the job's instances are running
+ */
+func (j *Job) idle() bool {
+	for _, instance := range j.Instances {
+		if !instance.Idle() {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * Succeeded reports whether every instance of a oneshot job has run to
+ * completion successfully
+ */
+func (j *Job) Succeeded() bool {
+	for _, instance := range j.Instances {
+		if !instance.Idle() || instance.GetStatus() != "succeeded" {
+			return false
+		}
+	}
+	return j.Oneshot
+}
+
+/*
+ * Failed reports whether an instance of a oneshot job has exhausted its
+ * retries without succeeding
+ */
+func (j *Job) Failed() bool {
+	for _, instance := range j.Instances {
+		if instance.Idle() && instance.GetStatus() == "failed" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * WaitForCompletion blocks until every instance has finished running,
+ * returning the first exit code not matching the expected exit code, or the
+ * expected exit code if all instances exited as expected
+ */
+func (j *Job) WaitForCompletion() int {
+	code, unexpected := 0, false
+	for _, instance := range j.Instances {
+		instance.WaitForIdle()
+		if exit := instance.ExitCode(); !unexpected {
+			code, unexpected = exit, exit != instance.ExpectedExit
+		}
+	}
+	return code
+}
+
+func (j *Job) String() string {
 	return fmt.Sprintf("Job %d", j.ID)
 }
+
+/*
+ * LogFields identifies the job in structured logs
+ */
+func (j *Job) LogFields() Fields {
+	return Fields{"job": j.ID}
+}
@@ -0,0 +1,234 @@
+package job
+
+import (
+	"sync"
+	"time"
+
+	INST "github.com/Travmatth/taskmaster/instance"
+	. "github.com/Travmatth/taskmaster/log"
+	SCHED "github.com/Travmatth/taskmaster/schedule"
+)
+
+const (
+	/*
+	 * OVERLAPSKIP signifies a tick is skipped while all instances are busy
+	 */
+	OVERLAPSKIP = iota
+	/*
+	 * OVERLAPQUEUE signifies a tick runs once an instance becomes free
+	 */
+	OVERLAPQUEUE
+	/*
+	 * OVERLAPREPLACE signifies the oldest run is stopped to start a new one
+	 */
+	OVERLAPREPLACE
+)
+
+/*
+ * HISTORYSIZE is the number of runs kept in a scheduled job's history
+ */
+const HISTORYSIZE = 20
+
+/*
+ * Run records a single completed run of a scheduled job
+ */
+type Run struct {
+	Instance int
+	Start    time.Time
+	Duration time.Duration
+	ExitCode int
+	Reason   string
+	Output   []string
+}
+
+/*
+ * Scheduler launches a job's instances on a cron schedule
+ */
+type Scheduler struct {
+	Schedule  *SCHED.Schedule
+	Overlap   int
+	History   []Run
+	Next      time.Time
+	queued    int
+	replacing map[*INST.Instance]bool
+	stop      chan struct{}
+	lock      sync.Mutex
+}
+
+/*
+ * enableSchedule starts launching instances at each tick of the schedule
+ */
+func (j *Job) enableSchedule() {
+	sched := j.Scheduler
+	defer sched.lock.Unlock()
+	sched.lock.Lock()
+	if sched.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	sched.stop = stop
+	sched.Next = sched.Schedule.Next(time.Now())
+	Log.Info(j, ": scheduled with", sched.Schedule)
+	// read under the lock, as the job may be stopped before the loop starts
+	first := sched.Next
+	go func() {
+		for next := first; !next.IsZero(); {
+			select {
+			case <-time.After(time.Until(next)):
+				j.tick()
+			case <-stop:
+				return
+			}
+			next = sched.Schedule.Next(time.Now())
+			sched.lock.Lock()
+			if sched.stop != stop {
+				sched.lock.Unlock()
+				return
+			}
+			sched.Next = next
+			sched.lock.Unlock()
+		}
+		Log.Info(j, ": schedule has no further runs")
+	}()
+}
+
+/*
+ * disableSchedule stops launching instances, dropping queued runs
+ */
+func (j *Job) disableSchedule() {
+	sched := j.Scheduler
+	defer sched.lock.Unlock()
+	sched.lock.Lock()
+	if sched.stop != nil {
+		close(sched.stop)
+		sched.stop = nil
+	}
+	sched.Next = time.Time{}
+	sched.queued = 0
+}
+
+/*
+ * tick launches a run on an idle instance, applying the overlap policy
+ * when every instance is still busy with a previous run. Instances being
+ * replaced are left to the goroutine replacing them
+ */
+func (j *Job) tick() {
+	sched := j.Scheduler
+	sched.lock.Lock()
+	for _, instance := range j.Instances {
+		if !sched.replacing[instance] && instance.Idle() {
+			sched.lock.Unlock()
+			Log.Info(instance, ": starting scheduled run")
+			instance.StartInstance(false)
+			return
+		}
+	}
+	switch sched.Overlap {
+	case OVERLAPQUEUE:
+		sched.queued++
+		sched.lock.Unlock()
+		Log.Info(j, ": previous run still active, queueing scheduled run")
+	case OVERLAPREPLACE:
+		oldest := j.oldestRun()
+		if oldest == nil {
+			sched.lock.Unlock()
+			Log.Info(j, ": previous run still being replaced, skipping scheduled run")
+			return
+		}
+		if sched.replacing == nil {
+			sched.replacing = make(map[*INST.Instance]bool)
+		}
+		sched.replacing[oldest] = true
+		sched.lock.Unlock()
+		Log.Info(oldest, ": replacing previous run with scheduled run")
+		go func() {
+			oldest.StopInstance(true)
+			oldest.WaitForIdle()
+			oldest.StartInstance(false)
+			sched.lock.Lock()
+			delete(sched.replacing, oldest)
+			sched.lock.Unlock()
+		}()
+	default:
+		sched.lock.Unlock()
+		Log.Info(j, ": previous run still active, skipping scheduled run")
+	}
+}
+
+/*
+ * oldestRun returns the instance whose run started first, ignoring those
+ * already being replaced. The caller must hold the scheduler lock
+ */
+func (j *Job) oldestRun() *INST.Instance {
+	var oldest *INST.Instance
+	var oldestStart time.Time
+	for _, instance := range j.Instances {
+		if j.Scheduler.replacing[instance] {
+			continue
+		}
+		instance.Mutex.RLock()
+		start := instance.StartTime
+		instance.Mutex.RUnlock()
+		if oldest == nil || start.Before(oldestStart) {
+			oldest, oldestStart = instance, start
+		}
+	}
+	return oldest
+}
+
+/*
+ * RecordRun adds the exited process to the job's history, and launches a
+ * queued run on the instance once it is free
+ */
+func (j *Job) RecordRun(instance *INST.Instance) {
+	instance.Mutex.RLock()
+	run := Run{
+		Instance: instance.InstanceID,
+		Start:    instance.StartTime,
+		Duration: instance.StopTime.Sub(instance.StartTime),
+		ExitCode: instance.State.ExitCode(),
+		Reason:   instance.ExitReason,
+		Output:   instance.LastOutput,
+	}
+	instance.Mutex.RUnlock()
+	sched := j.Scheduler
+	defer sched.lock.Unlock()
+	sched.lock.Lock()
+	sched.History = append(sched.History, run)
+	if len(sched.History) > HISTORYSIZE {
+		sched.History = sched.History[len(sched.History)-HISTORYSIZE:]
+	}
+	if sched.queued > 0 && sched.stop != nil {
+		sched.queued--
+		go func() {
+			instance.WaitForIdle()
+			Log.Info(instance, ": starting queued scheduled run")
+			instance.StartInstance(false)
+		}()
+	}
+}
+
+/*
+ * Runs returns a copy of the job's run history, oldest first
+ */
+func (j *Job) Runs() []Run {
+	if j.Scheduler == nil {
+		return nil
+	}
+	defer j.Scheduler.lock.Unlock()
+	j.Scheduler.lock.Lock()
+	return append([]Run{}, j.Scheduler.History...)
+}
+
+/*
+ * NextRun returns the time of the next scheduled run, or the zero time if
+ * the job is not scheduled
+ */
+func (j *Job) NextRun() time.Time {
+	if j.Scheduler == nil {
+		return time.Time{}
+	}
+	defer j.Scheduler.lock.Unlock()
+	j.Scheduler.lock.Lock()
+	return j.Scheduler.Next
+}
@@ -0,0 +1,254 @@
+package job
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	. "github.com/Travmatth/taskmaster/log"
+)
+
+/*
+ * errPollUnsupported is returned by waitReadable on platforms where sockets
+ * cannot be polled for connections
+ */
+var errPollUnsupported = fmt.Errorf("Socket Error: lazy activation requires linux")
+
+/*
+ * Socket is a listening socket owned by taskmaster and passed to every
+ * instance of the job, following the systemd socket activation protocol
+ */
+type Socket struct {
+	Name    string
+	Network string
+	Address string
+	Backlog int
+	Mode    os.FileMode
+	File    *os.File
+}
+
+/*
+ * ParseSocketAddress splits "tcp://host:port", "unix:///path", a bare
+ * "host:port" or a bare path into its network and address
+ */
+func ParseSocketAddress(addr string) (string, string) {
+	if n := strings.Index(addr, "://"); n != -1 {
+		return addr[:n], addr[n+3:]
+	} else if strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, ".") {
+		return "unix", addr
+	}
+	return "tcp", addr
+}
+
+/*
+ * Key identifies the socket when it is handed over during an upgrade
+ */
+func (s *Socket) Key(job int) string {
+	return fmt.Sprintf("socket %d %s://%s", job, s.Network, s.Address)
+}
+
+/*
+ * RemoveStaleSocket removes a unix socket left at path by a process which is
+ * no longer running, refusing to replace one still accepting connections or
+ * a file which is not a socket
+ */
+func RemoveStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("Socket Error: %s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("Socket Error: %s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+/*
+ * Listen binds the socket, creating the listening descriptor with the
+ * configured backlog, which net.Listen does not allow to be set
+ */
+func (s *Socket) Listen() error {
+	var family int
+	var sa syscall.Sockaddr
+	switch s.Network {
+	case "tcp", "tcp4", "tcp6":
+		addr, err := net.ResolveTCPAddr(s.Network, s.Address)
+		if err != nil {
+			return err
+		}
+		if ip4 := addr.IP.To4(); ip4 != nil || addr.IP == nil {
+			sa4 := &syscall.SockaddrInet4{Port: addr.Port}
+			copy(sa4.Addr[:], ip4)
+			family, sa = syscall.AF_INET, sa4
+		} else {
+			sa6 := &syscall.SockaddrInet6{Port: addr.Port}
+			copy(sa6.Addr[:], addr.IP.To16())
+			family, sa = syscall.AF_INET6, sa6
+		}
+	case "unix":
+		if err := RemoveStaleSocket(s.Address); err != nil {
+			return err
+		}
+		family, sa = syscall.AF_UNIX, &syscall.SockaddrUnix{Name: s.Address}
+	default:
+		return fmt.Errorf("Socket Error: unsupported network %q", s.Network)
+	}
+	// the fork lock keeps processes started meanwhile from inheriting it
+	syscall.ForkLock.RLock()
+	fd, err := syscall.Socket(family, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.CloseOnExec(fd)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	if family != syscall.AF_UNIX {
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	if family == syscall.AF_UNIX && s.Mode != 0 {
+		if err := os.Chmod(s.Address, s.Mode); err != nil {
+			syscall.Close(fd)
+			return err
+		}
+	}
+	if err := syscall.Listen(fd, s.Backlog); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	s.File = os.NewFile(uintptr(fd), s.Name)
+	return nil
+}
+
+/*
+ * Close closes the listening socket, removing the path of unix sockets
+ */
+func (s *Socket) Close() {
+	if s.File == nil {
+		return
+	}
+	s.File.Close()
+	s.File = nil
+	if s.Network == "unix" {
+		os.Remove(s.Address)
+	}
+}
+
+/*
+ * listen binds any of the job's sockets which are not yet open, passing
+ * them on to each instance
+ */
+func (j *Job) listen() error {
+	if len(j.Sockets) == 0 {
+		return nil
+	}
+	files, names := []*os.File{}, []string{}
+	for _, socket := range j.Sockets {
+		if socket.File == nil {
+			if err := socket.Listen(); err != nil {
+				return err
+			}
+			Log.Info(j, ": listening on", socket.Network, socket.Address)
+		}
+		files = append(files, socket.File)
+		names = append(names, socket.Name)
+	}
+	for _, instance := range j.Instances {
+		instance.Mutex.Lock()
+		instance.ListenFiles = files
+		instance.ListenNames = names
+		instance.Mutex.Unlock()
+	}
+	return nil
+}
+
+/*
+ * CloseSockets closes the job's listening sockets
+ */
+func (j *Job) CloseSockets() {
+	for _, socket := range j.Sockets {
+		socket.Close()
+	}
+}
+
+/*
+ * TakeSockets moves the open sockets of a previous version of the job
+ * listening on the same addresses to this job, so that reloading a changed
+ * job does not drop pending connections. The remaining sockets are closed
+ */
+func (j *Job) TakeSockets(prev *Job) {
+	for _, old := range prev.Sockets {
+		for _, socket := range j.Sockets {
+			if socket.File == nil && old.File != nil &&
+				socket.Network == old.Network && socket.Address == old.Address {
+				socket.File, old.File = old.File, nil
+			}
+		}
+	}
+	prev.CloseSockets()
+}
+
+/*
+ * activateOnConnection starts the job's instances once a connection is
+ * pending on any of its sockets, without accepting it, and waits for
+ * connections again once all of the instances have exited
+ */
+func (j *Job) activateOnConnection(cancel chan struct{}) {
+	for {
+		if !j.waitForConnection(cancel) {
+			return
+		}
+		Log.Info(j, ": connection received, activating")
+		for _, instance := range j.Instances {
+			instance.StartInstance(false)
+		}
+		for !j.idle() {
+			select {
+			case <-cancel:
+				return
+			case <-time.After(time.Duration(100) * time.Millisecond):
+			}
+		}
+		Log.Info(j, ": all instances exited, waiting for connections")
+	}
+}
+
+/*
+ * waitForConnection polls the job's sockets until one becomes readable,
+ * returning false if cancelled first or if the platform cannot poll them
+ */
+func (j *Job) waitForConnection(cancel chan struct{}) bool {
+	fds := []int{}
+	for _, socket := range j.Sockets {
+		fds = append(fds, int(socket.File.Fd()))
+	}
+	for {
+		ready, err := waitReadable(fds, time.Duration(200)*time.Millisecond)
+		select {
+		case <-cancel:
+			return false
+		default:
+		}
+		if err == errPollUnsupported {
+			Log.Info(j, ": unable to wait for connections:", err)
+			return false
+		} else if err != nil && err != syscall.EINTR {
+			Log.Info(j, ": failed waiting for connection:", err)
+			time.Sleep(time.Second)
+		} else if ready {
+			return true
+		}
+	}
+}
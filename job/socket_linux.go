@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package job
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+/*
+ * waitReadable waits up to timeout for any of fds to become readable,
+ * which for a listening socket means a connection is pending
+ */
+func waitReadable(fds []int, timeout time.Duration) (bool, error) {
+	var set syscall.FdSet
+	// the width of the words of the set differs between architectures
+	width := int(unsafe.Sizeof(set.Bits[0])) * 8
+	max := 0
+	for _, fd := range fds {
+		if fd >= len(set.Bits)*width {
+			return false, fmt.Errorf("Select Error: descriptor %d too large to select", fd)
+		}
+		set.Bits[fd/width] |= 1 << uint(fd%width)
+		if fd > max {
+			max = fd
+		}
+	}
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	n, err := syscall.Select(max+1, &set, nil, nil, &tv)
+	return n > 0, err
+}
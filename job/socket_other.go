@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package job
+
+import (
+	"time"
+)
+
+/*
+ * waitReadable is only supported on linux
+ */
+func waitReadable(fds []int, timeout time.Duration) (bool, error) {
+	return false, errPollUnsupported
+}
@@ -0,0 +1,160 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	INST "github.com/Travmatth/taskmaster/instance"
+	. "github.com/Travmatth/taskmaster/log"
+)
+
+const (
+	/*
+	 * WATCHRESTART signifies instances are restarted when a file changes
+	 */
+	WATCHRESTART = iota
+	/*
+	 * WATCHSIGNAL signifies instances are sent a signal when a file changes
+	 */
+	WATCHSIGNAL
+)
+
+/*
+ * Watcher acts on a job's instances when the files it watches change
+ */
+type Watcher struct {
+	Paths    []string
+	Exclude  []string
+	Debounce time.Duration
+	Action   int
+	Signal   os.Signal
+	stop     chan struct{}
+	lock     sync.Mutex
+}
+
+/*
+ * Excluded reports whether the path matches one of the exclude globs, either
+ * by its base name or as a whole
+ */
+func (w *Watcher) Excluded(path string) bool {
+	for _, pattern := range w.Exclude {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		} else if ok, _ = filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * enableWatch starts watching the job's paths for changes
+ */
+func (j *Job) enableWatch() {
+	w := j.Watcher
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	if w.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	events, err := watchPaths(w, stop)
+	if err != nil {
+		Log.Info(j, ": unable to watch files:", err)
+		return
+	}
+	w.stop = stop
+	Log.Info(j, ": watching", strings.Join(w.Paths, ", "))
+	go j.debounce(events, stop)
+}
+
+/*
+ * disableWatch stops watching the job's paths
+ */
+func (j *Job) disableWatch() {
+	w := j.Watcher
+	w.lock.Lock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	w.lock.Unlock()
+}
+
+/*
+ * debounce waits for changes to settle for the debounce period before acting
+ * on them, reporting the first file which changed
+ */
+func (j *Job) debounce(events <-chan string, stop chan struct{}) {
+	for {
+		var path string
+		select {
+		case changed, ok := <-events:
+			if !ok {
+				return
+			}
+			path = changed
+		case <-stop:
+			return
+		}
+		quiet := time.After(j.Watcher.Debounce)
+		for pending := true; pending; {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				quiet = time.After(j.Watcher.Debounce)
+			case <-quiet:
+				pending = false
+			case <-stop:
+				return
+			}
+		}
+		j.watchTriggered(path, stop)
+	}
+}
+
+/*
+ * watchTriggered performs the watch action on the job's running instances,
+ * resuming paused instances first so they can act on the stop signal
+ */
+func (j *Job) watchTriggered(path string, stop chan struct{}) {
+	j.lock.Lock()
+	instances := append([]*INST.Instance{}, j.Instances...)
+	j.lock.Unlock()
+	if j.Watcher.Action == WATCHSIGNAL {
+		Log.Info(j, ":", path, "changed, sending", j.Watcher.Signal)
+		for _, instance := range instances {
+			if instance.Running() {
+				instance.Signal(j.Watcher.Signal)
+			}
+		}
+		return
+	}
+	Log.Info(j, ":", path, "changed, restarting")
+	restart := instances[:0:0]
+	for _, instance := range instances {
+		if !instance.Idle() {
+			restart = append(restart, instance)
+			if instance.Paused() {
+				instance.Resume()
+			}
+			instance.StopInstance(false)
+		}
+	}
+	for _, instance := range restart {
+		instance.WaitForIdle()
+	}
+	select {
+	case <-stop:
+		return
+	default:
+	}
+	for _, instance := range restart {
+		instance.StartInstance(false)
+	}
+}
@@ -0,0 +1,164 @@
+//go:build linux
+// +build linux
+
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+
+	. "github.com/Travmatth/taskmaster/log"
+)
+
+// events reported by inotify which count as a change, see `man 7 inotify`
+const watchMask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+/*
+ * inotify tracks the watch descriptors added to an inotify instance
+ */
+type inotify struct {
+	fd      int
+	watcher *Watcher
+	paths   map[int]string
+}
+
+/*
+ * watchPaths watches the watcher's paths, descending into directories, and
+ * sends the path of each changed file until stop is closed
+ */
+func watchPaths(w *Watcher, stop chan struct{}) (<-chan string, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	in := &inotify{fd: fd, watcher: w, paths: make(map[int]string)}
+	for _, path := range w.Paths {
+		if err := in.add(path); err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+	events := make(chan string)
+	go in.read(events, stop)
+	return events, nil
+}
+
+/*
+ * add watches the path, and every directory beneath it not excluded
+ */
+func (in *inotify) add(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if path != root && in.watcher.Excluded(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if path != root && !info.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(in.fd, path, watchMask)
+		if err != nil {
+			return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+		}
+		in.paths[wd] = path
+		return nil
+	})
+}
+
+/*
+ * root reports whether the path is one of the configured paths
+ */
+func (in *inotify) root(path string) bool {
+	for _, root := range in.watcher.Paths {
+		if root == path {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * read waits for inotify events, translating them to the changed paths
+ */
+func (in *inotify) read(events chan string, stop chan struct{}) {
+	defer close(events)
+	defer syscall.Close(in.fd)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		_, err := waitReadable([]int{in.fd}, time.Duration(200)*time.Millisecond)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err != nil && err != syscall.EINTR {
+			Log.Info("Watch Error: failed waiting for inotify events:", err)
+			return
+		}
+		n, err := syscall.Read(in.fd, buf)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			Log.Info("Watch Error: failed reading inotify events:", err)
+			return
+		}
+		for _, path := range in.parse(buf[:n]) {
+			select {
+			case events <- path:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+/*
+ * parse decodes a buffer of inotify events, watching newly created
+ * directories and skipping excluded paths
+ */
+func (in *inotify) parse(buf []byte) []string {
+	var changed []string
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		start := offset + syscall.SizeofInotifyEvent
+		offset = start + int(event.Len)
+		dir, ok := in.paths[int(event.Wd)]
+		if !ok {
+			continue
+		} else if event.Mask&syscall.IN_IGNORED != 0 {
+			// files replaced by a rename lose their watch, so watch the
+			// new file at the same path
+			delete(in.paths, int(event.Wd))
+			if in.root(dir) && in.add(dir) == nil {
+				changed = append(changed, dir)
+			}
+			continue
+		}
+		path := dir
+		if event.Len > 0 {
+			name := buf[start:offset]
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			path = filepath.Join(dir, string(name))
+		}
+		if in.watcher.Excluded(path) {
+			continue
+		}
+		if event.Mask&syscall.IN_ISDIR != 0 &&
+			event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			if err := in.add(path); err != nil {
+				Log.Info("Watch Error: unable to watch", path, ":", err)
+			}
+		}
+		changed = append(changed, path)
+	}
+	return changed
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package job
+
+import (
+	"fmt"
+)
+
+/*
+ * watchPaths is only supported on linux
+ */
+func watchPaths(w *Watcher, stop chan struct{}) (<-chan string, error) {
+	return nil, fmt.Errorf("Watch Error: watching files requires inotify on linux")
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+/*
+ * Fields are keys & values describing a log message for structured logs,
+ * left out of the text log whose message already describes them
+ */
+type Fields map[string]interface{}
+
+/*
+ * Fielder is implemented by what log messages are about, such as jobs &
+ * instances, adding the fields identifying them to structured logs
+ */
+type Fielder interface {
+	LogFields() Fields
+}
+
+/*
+ * Event returns the fields naming the event a message records, along with
+ * the pairs of keys & values following the name
+ */
+func Event(name string, pairs ...interface{}) Fields {
+	fields := Fields{"event": name}
+	for n := 0; n+1 < len(pairs); n += 2 {
+		fields[fmt.Sprint(pairs[n])] = pairs[n+1]
+	}
+	return fields
+}
+
+/*
+ * fieldsBackend collects the fields of each message before passing it on,
+ * removing Fields from its arguments so text backends only print the message
+ */
+type fieldsBackend []logging.Backend
+
+/*
+ * NewFieldsBackend creates a backend logging to each of backends, which may
+ * print the message as text or as json with its fields
+ */
+func NewFieldsBackend(backends ...logging.Backend) logging.Backend {
+	return fieldsBackend(backends)
+}
+
+func (b fieldsBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	fields := Fields{}
+	args := rec.Args[:0]
+	for _, arg := range rec.Args {
+		if f, ok := arg.(Fields); ok {
+			for key, val := range f {
+				fields[key] = val
+			}
+			continue
+		} else if f, ok := arg.(Fielder); ok {
+			for key, val := range f.LogFields() {
+				fields[key] = val
+			}
+		}
+		args = append(args, arg)
+	}
+	rec.Args = args
+	var err error
+	for _, backend := range b {
+		if j, ok := backend.(*jsonBackend); ok {
+			err = j.log(level, calldepth+1, rec, fields)
+		} else {
+			err = backend.Log(level, calldepth+1, rec)
+		}
+	}
+	return err
+}
+
+/*
+ * jsonBackend writes each message as a line of json holding its time, level,
+ * source file & message along with its fields
+ */
+type jsonBackend struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+func (b *jsonBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	return b.log(level, calldepth+1, rec, nil)
+}
+
+func (b *jsonBackend) log(level logging.Level, calldepth int, rec *logging.Record, fields Fields) error {
+	entry := Fields{}
+	for key, val := range fields {
+		entry[key] = val
+	}
+	entry["time"] = rec.Time.Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["message"] = jsonMessage(rec)
+	if _, file, line, ok := runtime.Caller(calldepth + 1); ok {
+		entry["file"] = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	defer b.lock.Unlock()
+	b.lock.Lock()
+	_, err = b.w.Write(append(data, '\n'))
+	return err
+}
+
+/*
+ * jsonMessage returns the message without what it is about, which is given
+ * by its fields, e.g. "exited with status: exit status 1" rather than "Job 1
+ * Instance 0 : exited with status: exit status 1"
+ */
+func jsonMessage(rec *logging.Record) string {
+	args := []interface{}{}
+	for _, arg := range rec.Args {
+		if _, ok := arg.(Fielder); !ok || len(args) != 0 {
+			args = append(args, arg)
+		}
+	}
+	if len(args) == len(rec.Args) {
+		return rec.Message()
+	}
+	message := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	return strings.TrimSpace(strings.TrimPrefix(message, ":"))
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+type testInstance struct{}
+
+func (testInstance) String() string {
+	return "Job 3 Instance 1"
+}
+
+func (testInstance) LogFields() Fields {
+	return Fields{"job": 3, "instance": 1}
+}
+
+func testLogger(backend logging.Backend) *logging.Logger {
+	logger := logging.MustGetLogger("fields_test")
+	leveled := logging.AddModuleLevel(NewFieldsBackend(backend))
+	leveled.SetLevel(logging.DEBUG, "")
+	logger.SetBackend(leveled)
+	return logger
+}
+
+func TestFieldsTextOmitsFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := testLogger(logging.NewBackendFormatter(
+		logging.NewLogBackend(&buf, "", 0),
+		logging.MustStringFormatter(`%{message}`)))
+	logger.Info(testInstance{}, ": exited with status:", "exit status 2",
+		Event("exited", "exit_code", 2))
+	expected := "Job 3 Instance 1 : exited with status: exit status 2\n"
+	if buf.String() != expected {
+		t.Errorf("Text log should leave out fields:\n%q\n%q", buf.String(), expected)
+	}
+}
+
+func TestFieldsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := testLogger(&jsonBackend{w: &buf})
+	logger.Warning(testInstance{}, ": exited with status:", "signal: killed",
+		Event("exited", "exit_code", -1, "signal", "SIGKILL"))
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log should be json: %v %q", err, buf.String())
+	}
+	expected := map[string]interface{}{
+		"job":       3.0,
+		"instance":  1.0,
+		"event":     "exited",
+		"exit_code": -1.0,
+		"signal":    "SIGKILL",
+		"level":     "WARNING",
+		"message":   "exited with status: signal: killed",
+	}
+	for key, val := range expected {
+		if entry[key] != val {
+			t.Errorf("Log field %s should be %v, is %v", key, val, entry[key])
+		}
+	}
+	if file, _ := entry["file"].(string); !strings.HasPrefix(file, "fields_test.go:") {
+		t.Errorf("Log should give the file logging: %q", file)
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("Log should have a time: %q", buf.String())
+	}
+}
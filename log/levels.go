@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/op/go-logging"
+)
+
+/*
+ * levels filters messages by the level set for the package logging them,
+ * such as instance or supervisor, falling back to the level set for all
+ * packages. Packages are told apart by the caller of each message, as every
+ * package logs through the one Log
+ */
+type levels struct {
+	backend logging.Backend
+	level   logging.Level
+	modules map[string]logging.Level
+	lock    sync.RWMutex
+}
+
+var logLevels *levels
+
+/*
+ * ParseLevel translates a level name, e.g. DEBUG, or its number, 0 for
+ * CRITICAL to 5 for DEBUG
+ */
+func ParseLevel(name string) (logging.Level, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < int(logging.CRITICAL) || n > int(logging.DEBUG) {
+			return logging.INFO, fmt.Errorf("unknown log level %s", name)
+		}
+		return logging.Level(n), nil
+	}
+	level, err := logging.LogLevel(name)
+	if err != nil {
+		return logging.INFO, fmt.Errorf("unknown log level %s", name)
+	}
+	return level, nil
+}
+
+/*
+ * SetLevels sets the levels logged from a list of levels separated by spaces
+ * or commas: a level alone sets the level of every package, clearing any set
+ * per package, & package=level sets the level of one package, e.g.
+ * "INFO instance=DEBUG"
+ */
+func SetLevels(spec string) error {
+	if logLevels == nil {
+		return fmt.Errorf("log is not open")
+	}
+	return logLevels.set(spec)
+}
+
+/*
+ * Levels describes the levels logged, in the form taken by SetLevels
+ */
+func Levels() string {
+	if logLevels == nil {
+		return ""
+	}
+	return logLevels.String()
+}
+
+func newLevels(backend logging.Backend, spec string) (*levels, error) {
+	l := &levels{backend: backend, level: logging.INFO}
+	return l, l.set(spec)
+}
+
+/*
+ * set applies a spec to a copy of the levels, so that an invalid spec
+ * leaves them as they were
+ */
+func (l *levels) set(spec string) error {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	level, modules := l.level, make(map[string]logging.Level)
+	for module, val := range l.modules {
+		modules[module] = val
+	}
+	words := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t'
+	})
+	for _, word := range words {
+		module, name := "", word
+		if n := strings.Index(word, "="); n != -1 {
+			module, name = strings.ToLower(word[:n]), word[n+1:]
+		}
+		val, err := ParseLevel(name)
+		if err != nil {
+			return err
+		} else if module == "" {
+			level, modules = val, make(map[string]logging.Level)
+		} else {
+			modules[module] = val
+		}
+	}
+	l.level, l.modules = level, modules
+	return nil
+}
+
+func (l *levels) String() string {
+	defer l.lock.RUnlock()
+	l.lock.RLock()
+	words := []string{l.level.String()}
+	for module, level := range l.modules {
+		words = append(words, module+"="+level.String())
+	}
+	sort.Strings(words[1:])
+	return strings.Join(words, " ")
+}
+
+/*
+ * GetLevel returns the level of a package, or of every package if module is
+ * empty
+ */
+func (l *levels) GetLevel(module string) logging.Level {
+	defer l.lock.RUnlock()
+	l.lock.RLock()
+	if level, ok := l.modules[module]; ok && module != "" {
+		return level
+	}
+	return l.level
+}
+
+/*
+ * SetLevel sets the level of a package, or of every package if module is
+ * empty
+ */
+func (l *levels) SetLevel(level logging.Level, module string) {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	if module == "" {
+		l.level = level
+	} else {
+		l.modules[module] = level
+	}
+}
+
+/*
+ * IsEnabledFor reports whether any package logs the level, the package a
+ * message comes from being checked once it is logged
+ */
+func (l *levels) IsEnabledFor(level logging.Level, module string) bool {
+	defer l.lock.RUnlock()
+	l.lock.RLock()
+	enabled := level <= l.level
+	for _, val := range l.modules {
+		enabled = enabled || level <= val
+	}
+	return enabled
+}
+
+func (l *levels) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	if level > l.GetLevel(callerPackage(calldepth+1)) {
+		return nil
+	}
+	return l.backend.Log(level, calldepth+1, rec)
+}
+
+/*
+ * callerPackage returns the name of the package of the function calldepth
+ * frames up the stack, e.g. instance for taskmaster/instance
+ */
+func callerPackage(calldepth int) string {
+	pc, _, _, ok := runtime.Caller(calldepth + 1)
+	if !ok {
+		return ""
+	}
+	name := runtime.FuncForPC(pc).Name()
+	if n := strings.LastIndex(name, "/"); n != -1 {
+		name = name[n+1:]
+	}
+	if n := strings.Index(name, "."); n != -1 {
+		name = name[:n]
+	}
+	return name
+}
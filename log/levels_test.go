@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestLevelsParse(t *testing.T) {
+	l, err := newLevels(nil, "4")
+	if err != nil || l.String() != "INFO" {
+		t.Errorf("Levels should accept level numbers: %v %s", err, l)
+	}
+	if err := l.set("debug, instance=warning supervisor=0"); err != nil {
+		t.Fatal(err)
+	} else if l.String() != "DEBUG instance=WARNING supervisor=CRITICAL" {
+		t.Errorf("Levels should set the level of packages: %s", l)
+	}
+	if err := l.set("instance=loud"); err == nil {
+		t.Errorf("Levels should reject unknown levels")
+	} else if l.String() != "DEBUG instance=WARNING supervisor=CRITICAL" {
+		t.Errorf("Levels should be left unchanged by an invalid spec: %s", l)
+	}
+	if err := l.set("notice"); err != nil || l.String() != "NOTICE" {
+		t.Errorf("A level alone should clear the levels of packages: %v %s", err, l)
+	}
+}
+
+func TestLevelsFilterByPackage(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := newLevels(logging.NewBackendFormatter(
+		logging.NewLogBackend(&buf, "", 0),
+		logging.MustStringFormatter(`%{level} %{message}`)), "WARNING")
+	logger := logging.MustGetLogger("levels_test")
+	logger.SetBackend(l)
+	logger.Info("hidden")
+	l.set("log=DEBUG")
+	logger.Debug("shown")
+	l.set("INFO instance=DEBUG")
+	logger.Debug("hidden")
+	if buf.String() != "DEBUG shown\n" {
+		t.Errorf("Levels should filter by the package logging: %q", buf.String())
+	}
+}
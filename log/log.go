@@ -1,57 +1,131 @@
 package utils
 
 import (
+	"fmt"
 	"io"
+	"log/syslog"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/op/go-logging"
 )
 
 var Log *logging.Logger
 
-func setLogLevel(level string) logging.Level {
-	switch strings.ToUpper(level) {
-	case "CRITICAL":
-		return logging.CRITICAL
-	case "ERROR":
-		return logging.ERROR
-	case "WARNING":
-		return logging.WARNING
-	case "NOTICE":
-		return logging.NOTICE
-	case "INFO":
-		return logging.INFO
-	case "DEBUG":
-		return logging.DEBUG
-	default:
-		return logging.INFO
-	}
+/*
+ * NewLogger creates logger for use in program, writing text or, with format
+ * json, a json object per line
+ */
+func NewLogger(name, level, format string) error {
+	return newLogger(name, level, format, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
 }
 
 /*
- * NewLogger creates logger for use in program
+ * ResumeLogger creates logger appending to an existing log, used when
+ * taskmaster re-executes itself
  */
-func NewLogger(name, level string) error {
+func ResumeLogger(name, level, format string) error {
+	return newLogger(name, level, format, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+}
+
+func newLogger(name, level, format string, flags int) error {
 	var out io.Writer
 	var err error
 
-	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
-	if name == strings.ToLower("stdout") {
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown log format %s", format)
+	} else if name == strings.ToLower("stdout") {
 		out = os.Stdout
+		logOut = nil
 	} else if f, err := os.OpenFile(name, flags, 0666); err != nil {
 		return err
 	} else {
-		out = f
+		logOut = &logFile{name: name, file: f}
+		out = logOut
+	}
+	var backends []logging.Backend
+	if format == "json" {
+		backends = append(backends, &jsonBackend{w: out})
+	} else {
+		backends = append(backends, logging.NewBackendFormatter(
+			logging.NewLogBackend(out, "", 0),
+			logging.MustStringFormatter(
+				`[%{time:2006-01-02 15:04:05}] [%{level:.4s}] [%{shortfile}] - %{message}`,
+			)))
+	}
+	if Syslogger != nil {
+		backends = append(backends,
+			logging.NewBackendFormatter(syslogBackend{Syslogger},
+				logging.MustStringFormatter(`%{message}`)))
+	}
+	leveled, err := newLevels(NewFieldsBackend(backends...), level)
+	if err != nil {
+		return err
 	}
-	loggingBackend := logging.NewBackendFormatter(
-		logging.NewLogBackend(out, "", 0),
-		logging.MustStringFormatter(
-			`[%{time:2006-01-02 15:04:05}] [%{level:.4s}] [%{shortfile}] - %{message}`,
-		))
 	Log, err = logging.GetLogger("taskmaster")
-	leveledBackend := logging.AddModuleLevel(loggingBackend)
-	leveledBackend.SetLevel(setLogLevel(level), "")
-	Log.SetBackend(leveledBackend)
+	logLevels = leveled
+	Log.SetBackend(leveled)
 	return err
 }
+
+/*
+ * logFile is the file taskmaster logs to, which may be reopened while
+ * messages are written to it
+ */
+type logFile struct {
+	name string
+	file *os.File
+	lock sync.Mutex
+}
+
+var logOut *logFile
+
+func (l *logFile) Write(p []byte) (int, error) {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	return l.file.Write(p)
+}
+
+/*
+ * ReopenLog reopens the file taskmaster logs to, picking up the file created
+ * in its place after logrotate or another tool moved it away
+ */
+func ReopenLog() error {
+	l := logOut
+	if l == nil {
+		return nil
+	}
+	f, err := os.OpenFile(l.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	old := l.file
+	l.file = f
+	l.lock.Unlock()
+	return old.Close()
+}
+
+/*
+ * syslogBackend sends taskmaster's log to syslog under its tag & pid, with
+ * the severity of each message's level
+ */
+type syslogBackend struct {
+	syslog *Syslog
+}
+
+func (b syslogBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	severity := map[logging.Level]syslog.Priority{
+		logging.CRITICAL: syslog.LOG_CRIT,
+		logging.ERROR:    syslog.LOG_ERR,
+		logging.WARNING:  syslog.LOG_WARNING,
+		logging.NOTICE:   syslog.LOG_NOTICE,
+		logging.INFO:     syslog.LOG_INFO,
+		logging.DEBUG:    syslog.LOG_DEBUG,
+	}[level]
+	b.syslog.Send(severity, b.syslog.Tag, strconv.Itoa(os.Getpid()), "-",
+		rec.Formatted(calldepth+1))
+	return nil
+}
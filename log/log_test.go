@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taskmaster.log")
+	if err := NewLogger(path, "INFO", ""); err != nil {
+		t.Fatal(err)
+	}
+	Log.Info("before")
+	os.Rename(path, path+".1")
+	if err := ReopenLog(); err != nil {
+		t.Fatal(err)
+	}
+	Log.Info("after")
+	rotated, _ := ioutil.ReadFile(path + ".1")
+	reopened, _ := ioutil.ReadFile(path)
+	if !strings.HasSuffix(string(rotated), "- before\n") {
+		t.Errorf("Log should have written to the moved file: %q", rotated)
+	} else if !strings.HasSuffix(string(reopened), "- after\n") ||
+		strings.Count(string(reopened), "\n") != 1 {
+		t.Errorf("Log should write to a new file once reopened: %q", reopened)
+	}
+}
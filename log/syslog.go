@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * SYSLOGTIMEOUT bounds how long a message may take to send, so that a hung
+ * syslog daemon cannot stall taskmaster or the output of its jobs
+ */
+const SYSLOGTIMEOUT = time.Second
+
+/*
+ * localSyslog are the sockets tried, in order, when no address is given
+ */
+var localSyslog = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+/*
+ * facilities map facility names to their syslog priority
+ */
+var facilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+/*
+ * severities map severity names to their syslog priority
+ */
+var severities = map[string]syslog.Priority{
+	"emerg":   syslog.LOG_EMERG,
+	"alert":   syslog.LOG_ALERT,
+	"crit":    syslog.LOG_CRIT,
+	"err":     syslog.LOG_ERR,
+	"error":   syslog.LOG_ERR,
+	"warning": syslog.LOG_WARNING,
+	"warn":    syslog.LOG_WARNING,
+	"notice":  syslog.LOG_NOTICE,
+	"info":    syslog.LOG_INFO,
+	"debug":   syslog.LOG_DEBUG,
+}
+
+/*
+ * Syslog sends messages to a syslog daemon over a unix socket, udp or tcp,
+ * formatted as RFC 3164 or RFC 5424. The connection is made on the first
+ * message & remade once when sending fails, so a daemon restarting loses
+ * at most the message in flight
+ */
+type Syslog struct {
+	Network  string
+	Address  string
+	Facility syslog.Priority
+	Tag      string
+	RFC5424  bool
+	hostname string
+	conn     net.Conn
+	stream   bool
+	lock     sync.Mutex
+}
+
+/*
+ * Syslogger is the syslog taskmaster's own log & the output of jobs are sent
+ * to, nil until configured or first needed
+ */
+var (
+	Syslogger  *Syslog
+	syslogLock sync.Mutex
+)
+
+/*
+ * NewSyslog creates a Syslog sending to target, a path to a unix socket,
+ * unix://path, udp://host:port or tcp://host:port, or the local daemon if
+ * empty. Facility is a name such as daemon or local0 & format is rfc3164 or
+ * rfc5424
+ */
+func NewSyslog(target, facility, tag, format string) (*Syslog, error) {
+	s := &Syslog{Facility: syslog.LOG_DAEMON, Tag: tag}
+	if s.Tag == "" {
+		s.Tag = "taskmaster"
+	}
+	switch {
+	case target == "":
+	case strings.HasPrefix(target, "udp://"), strings.HasPrefix(target, "tcp://"):
+		s.Network, s.Address = target[:3], target[len("udp://"):]
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			return nil, fmt.Errorf("invalid syslog address %s: %v", target, err)
+		}
+	case strings.HasPrefix(target, "unix://"):
+		s.Address = strings.TrimPrefix(target, "unix://")
+	case strings.HasPrefix(target, "/"):
+		s.Address = target
+	default:
+		return nil, fmt.Errorf("invalid syslog address %s", target)
+	}
+	if facility != "" {
+		if val, ok := facilities[strings.ToLower(facility)]; !ok {
+			return nil, fmt.Errorf("unknown syslog facility %s", facility)
+		} else {
+			s.Facility = val
+		}
+	}
+	switch strings.ToLower(format) {
+	case "", "rfc3164":
+	case "rfc5424":
+		s.RFC5424 = true
+	default:
+		return nil, fmt.Errorf("unknown syslog format %s", format)
+	}
+	s.hostname, _ = os.Hostname()
+	return s, nil
+}
+
+/*
+ * SetSyslog sets the syslog taskmaster's log & the output of jobs are sent to
+ */
+func SetSyslog(s *Syslog) {
+	defer syslogLock.Unlock()
+	syslogLock.Lock()
+	Syslogger = s
+}
+
+/*
+ * DefaultSyslog returns the syslog set by SetSyslog, or one sending to the
+ * local daemon if none was set
+ */
+func DefaultSyslog() *Syslog {
+	defer syslogLock.Unlock()
+	syslogLock.Lock()
+	if Syslogger == nil {
+		Syslogger, _ = NewSyslog("", "", "", "")
+	}
+	return Syslogger
+}
+
+/*
+ * ParseSeverity translates a severity name such as info or err
+ */
+func ParseSeverity(name string) (syslog.Priority, error) {
+	if val, ok := severities[strings.ToLower(name)]; ok {
+		return val, nil
+	}
+	return 0, fmt.Errorf("unknown syslog severity %s", name)
+}
+
+/*
+ * Send sends a message with the given severity, from app, the tag or
+ * app-name of the message, with the process id & message id given, - if
+ * there are none
+ */
+func (s *Syslog) Send(severity syslog.Priority, app, procid, msgid, msg string) error {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	msg = strings.TrimRight(msg, "\n")
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(SYSLOGTIMEOUT))
+		if _, err = s.conn.Write(s.frame(s.format(severity, app, procid, msgid, msg))); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+/*
+ * Close closes the connection to the daemon, the next message reconnecting
+ */
+func (s *Syslog) Close() error {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+/*
+ * connect dials the daemon, trying a datagram & then a stream connection
+ * for unix sockets
+ */
+func (s *Syslog) connect() (err error) {
+	if s.Network != "" {
+		s.conn, err = net.DialTimeout(s.Network, s.Address, SYSLOGTIMEOUT)
+		s.stream = s.Network == "tcp"
+		return err
+	}
+	paths := localSyslog
+	if s.Address != "" {
+		paths = []string{s.Address}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			if s.conn, err = net.DialTimeout(network, path, SYSLOGTIMEOUT); err == nil {
+				s.stream = network == "unix"
+				return nil
+			}
+		}
+	}
+	return err
+}
+
+/*
+ * local reports whether messages go to a daemon on this host, which does not
+ * expect a hostname in RFC 3164 messages
+ */
+func (s *Syslog) local() bool {
+	return s.Network == ""
+}
+
+/*
+ * format formats a message as RFC 5424, or RFC 3164 with the procid as the
+ * pid following the tag
+ */
+func (s *Syslog) format(severity syslog.Priority, app, procid, msgid, msg string) string {
+	pri := s.Facility&^0x07 | severity&0x07
+	if s.RFC5424 {
+		hostname := s.hostname
+		if hostname == "" {
+			hostname = "-"
+		}
+		timestamp := time.Now().Format("2006-01-02T15:04:05.000000Z07:00")
+		return fmt.Sprintf("<%d>1 %s %s %s %s %s - %s", pri, timestamp,
+			hostname, nilValue(app, 48), nilValue(procid, 128),
+			nilValue(msgid, 32), msg)
+	}
+	tag := app
+	if procid != "" && procid != "-" {
+		tag += "[" + procid + "]"
+	}
+	timestamp := time.Now().Format(time.Stamp)
+	if s.local() || s.hostname == "" {
+		return fmt.Sprintf("<%d>%s %s: %s", pri, timestamp, tag, msg)
+	}
+	return fmt.Sprintf("<%d>%s %s %s: %s", pri, timestamp, s.hostname, tag, msg)
+}
+
+/*
+ * frame frames a message for the connection, streams needing to delimit
+ * messages: RFC 5424 messages by their length, RFC 3164 ones by a newline
+ */
+func (s *Syslog) frame(msg string) []byte {
+	if !s.stream {
+		return []byte(msg)
+	} else if s.RFC5424 {
+		return []byte(fmt.Sprintf("%d %s", len(msg), msg))
+	}
+	return []byte(msg + "\n")
+}
+
+/*
+ * nilValue replaces an empty header field with -, & truncates it to the
+ * length RFC 5424 allows
+ */
+func nilValue(field string, max int) string {
+	field = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, field)
+	if field == "" {
+		return "-"
+	} else if len(field) > max {
+		return field[:max]
+	}
+	return field
+}
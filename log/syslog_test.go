@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"log/syslog"
+	"net"
+	"regexp"
+	"testing"
+)
+
+func TestSyslogNewSyslog(t *testing.T) {
+	for _, target := range []string{"", "/dev/log", "unix:///run/log", "udp://127.0.0.1:514", "tcp://logs:601"} {
+		if _, err := NewSyslog(target, "local0", "", "rfc5424"); err != nil {
+			t.Errorf("NewSyslog should accept %q: %v", target, err)
+		}
+	}
+	for _, target := range []string{"logs:514", "udp://logs", "http://logs:514"} {
+		if _, err := NewSyslog(target, "", "", ""); err == nil {
+			t.Errorf("NewSyslog should reject %q", target)
+		}
+	}
+	if _, err := NewSyslog("", "local9", "", ""); err == nil {
+		t.Errorf("NewSyslog should reject unknown facilities")
+	} else if _, err := NewSyslog("", "", "", "rfc1234"); err == nil {
+		t.Errorf("NewSyslog should reject unknown formats")
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	s, _ := NewSyslog("udp://127.0.0.1:514", "local3", "", "")
+	s.hostname = "host"
+	msg := s.format(syslog.LOG_ERR, "web", "42", "stderr", "failed")
+	if !regexp.MustCompile(`^<155>\w{3} [ \d]\d \d\d:\d\d:\d\d host web\[42\]: failed$`).MatchString(msg) {
+		t.Errorf("RFC 3164 message malformed: %q", msg)
+	}
+	s.RFC5424 = true
+	msg = s.format(syslog.LOG_INFO, "web server", "", "stdout", "ok")
+	if !regexp.MustCompile(`^<158>1 \d{4}-\d\d-\d\dT\S+ host web_server - stdout - ok$`).MatchString(msg) {
+		t.Errorf("RFC 5424 message malformed: %q", msg)
+	}
+	s.Network = ""
+	s.RFC5424 = false
+	msg = s.format(syslog.LOG_INFO, "web", "-", "-", "ok")
+	if !regexp.MustCompile(`^<158>\w{3} [ \d]\d \d\d:\d\d:\d\d web: ok$`).MatchString(msg) {
+		t.Errorf("RFC 3164 message to the local daemon malformed: %q", msg)
+	}
+}
+
+func TestSyslogFrame(t *testing.T) {
+	s := &Syslog{}
+	if msg := string(s.frame("<14>msg")); msg != "<14>msg" {
+		t.Errorf("Datagrams should not be framed: %q", msg)
+	}
+	s.stream = true
+	if msg := string(s.frame("<14>msg")); msg != "<14>msg\n" {
+		t.Errorf("RFC 3164 messages should end in a newline: %q", msg)
+	}
+	s.RFC5424 = true
+	if msg := string(s.frame("<14>1 msg")); msg != "9 <14>1 msg" {
+		t.Errorf("RFC 5424 messages should be prefixed by their length: %q", msg)
+	}
+}
+
+func TestSyslogSend(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s, _ := NewSyslog("udp://"+conn.LocalAddr().String(), "", "tm", "rfc5424")
+	defer s.Close()
+	if err := s.Send(syslog.LOG_NOTICE, s.Tag, "7", "-", "started\n"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if !regexp.MustCompile(`^<29>1 \S+ \S+ tm 7 - - started$`).Match(buf[:n]) {
+		t.Errorf("Send should send one message per datagram: %q", buf[:n])
+	}
+}
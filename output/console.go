@@ -0,0 +1,86 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Travmatth/taskmaster/pty"
+)
+
+/*
+ * colors are the ANSI colors labels cycle through
+ */
+var colors = []string{"36", "33", "32", "35", "31", "34", "96", "93", "92", "95", "91", "94"}
+
+/*
+ * Console interleaves the output of processes onto one writer a line at a
+ * time, framing each line with a timestamp & the label of the process it
+ * came from, colored when writing to a terminal
+ */
+type Console struct {
+	w     io.Writer
+	color bool
+	width int
+	now   func() time.Time
+	lock  sync.Mutex
+}
+
+var (
+	stdout     *Console
+	stdoutOnce sync.Once
+)
+
+/*
+ * NewConsole creates a Console writing to w, coloring labels if color is set
+ */
+func NewConsole(w io.Writer, color bool) *Console {
+	return &Console{w: w, color: color, now: time.Now}
+}
+
+/*
+ * Stdout returns the Console writing to taskmaster's stdout, colored when it
+ * is a terminal & NO_COLOR is not set
+ */
+func Stdout() *Console {
+	stdoutOnce.Do(func() {
+		color := pty.IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+		stdout = NewConsole(os.Stdout, color)
+	})
+	return stdout
+}
+
+/*
+ * Source returns a writer whose lines are written to the console under the
+ * label, colored by the color numbered n. Labels are padded to the widest
+ * label seen so that the output lines up
+ */
+func (c *Console) Source(label string, n int) *LineWriter {
+	c.lock.Lock()
+	if len(label) > c.width {
+		c.width = len(label)
+	}
+	c.lock.Unlock()
+	if n < 0 {
+		n = -n
+	}
+	color := colors[n%len(colors)]
+	return NewLineWriter(func(line []byte) {
+		c.line(label, color, line)
+	})
+}
+
+/*
+ * line writes a line to the console
+ */
+func (c *Console) line(label, color string, line []byte) {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+	label = fmt.Sprintf("%-*s |", c.width, label)
+	if c.color {
+		label = "\x1b[" + color + "m" + label + "\x1b[0m"
+	}
+	fmt.Fprintf(c.w, "%s %s %s\n", c.now().Format("15:04:05"), label, line)
+}
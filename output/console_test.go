@@ -0,0 +1,53 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func testConsole(color bool) (*Console, *bytes.Buffer) {
+	var buf bytes.Buffer
+	c := NewConsole(&buf, color)
+	c.now = func() time.Time {
+		return time.Date(2020, 1, 1, 10, 21, 33, 0, time.UTC)
+	}
+	return c, &buf
+}
+
+func TestOutputConsoleFramesLines(t *testing.T) {
+	c, buf := testConsole(false)
+	web := c.Source("web.0", 0)
+	worker := c.Source("worker.1", 1)
+	web.Write([]byte("first\nsec"))
+	worker.Write([]byte("working\r\n"))
+	web.Write([]byte("ond\n"))
+	expected := "10:21:33 web.0    | first\n" +
+		"10:21:33 worker.1 | working\n" +
+		"10:21:33 web.0    | second\n"
+	if buf.String() != expected {
+		t.Errorf("Console should interleave whole lines:\n%q\n%q", buf.String(), expected)
+	}
+}
+
+func TestOutputConsoleFlush(t *testing.T) {
+	c, buf := testConsole(false)
+	web := c.Source("web.0", 0)
+	web.Write([]byte("unterminated"))
+	if buf.Len() != 0 {
+		t.Errorf("Console should hold back partial lines: %q", buf.String())
+	}
+	web.Flush()
+	web.Flush()
+	if buf.String() != "10:21:33 web.0 | unterminated\n" {
+		t.Errorf("Flush should write the partial line once: %q", buf.String())
+	}
+}
+
+func TestOutputConsoleColors(t *testing.T) {
+	c, buf := testConsole(true)
+	c.Source("web.0", 1).Write([]byte("line\n"))
+	if buf.String() != "10:21:33 \x1b[33mweb.0 |\x1b[0m line\n" {
+		t.Errorf("Console should color labels: %q", buf.String())
+	}
+}
@@ -0,0 +1,103 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/*
+ * Frame describes where a line of output came from, the fields an output
+ * prefix may use, e.g. "{{.Job}}.{{.Instance}} {{.Stream}}: "
+ */
+type Frame struct {
+	Job      int
+	Instance int
+	Pid      int
+	Stream   string
+}
+
+/*
+ * Framing is how lines of output written to log files are framed: stamped
+ * with the time they were written, as rfc3339 or unix seconds, & prefixed
+ * with a template of their Frame. The zero Framing writes output as is
+ */
+type Framing struct {
+	Timestamps string
+	Prefix     *template.Template
+	now        func() time.Time
+}
+
+/*
+ * NewFraming creates the Framing stamping lines with timestamps, rfc3339,
+ * unix or none, & prefixing them with the prefix template
+ */
+func NewFraming(timestamps, prefix string) (Framing, error) {
+	var framing Framing
+	switch strings.ToLower(timestamps) {
+	case "", "none":
+	case "rfc3339", "unix":
+		framing.Timestamps = strings.ToLower(timestamps)
+	default:
+		return framing, fmt.Errorf("unknown timestamps %s", timestamps)
+	}
+	if prefix != "" {
+		tmpl, err := template.New("prefix").Parse(prefix)
+		if err != nil {
+			return framing, err
+		}
+		// fields the Frame lacks are only reported when it is executed
+		if err := tmpl.Execute(ioutil.Discard, Frame{}); err != nil {
+			return framing, err
+		}
+		framing.Prefix = tmpl
+	}
+	return framing, nil
+}
+
+/*
+ * Enabled reports whether lines are framed, or written as is
+ */
+func (f Framing) Enabled() bool {
+	return f.Timestamps != "" || f.Prefix != nil
+}
+
+/*
+ * Writer returns a LineWriter writing each line to w framed with the Frame
+ * returned by frame, called for each line as the pid changes between runs.
+ * Lines are written whole, so that those of streams sharing w do not mix
+ */
+func (f Framing) Writer(w io.Writer, frame func() Frame) *LineWriter {
+	return NewLineWriter(func(line []byte) {
+		var buf bytes.Buffer
+		if f.Timestamps != "" {
+			buf.WriteString(f.timestamp())
+			buf.WriteByte(' ')
+		}
+		if f.Prefix != nil {
+			f.Prefix.Execute(&buf, frame())
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		w.Write(buf.Bytes())
+	})
+}
+
+/*
+ * timestamp formats the current time, to the millisecond
+ */
+func (f Framing) timestamp() string {
+	now := time.Now()
+	if f.now != nil {
+		now = f.now()
+	}
+	if f.Timestamps == "unix" {
+		return strconv.FormatFloat(float64(now.UnixNano()/1e6)/1e3, 'f', 3, 64)
+	}
+	return now.Format("2006-01-02T15:04:05.000Z07:00")
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFraming(t *testing.T, timestamps, prefix string) Framing {
+	framing, err := NewFraming(timestamps, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	framing.now = func() time.Time {
+		return time.Date(2020, 1, 1, 10, 21, 33, 5e8, time.UTC)
+	}
+	return framing
+}
+
+func TestOutputFramingPrefixesLines(t *testing.T) {
+	var buf bytes.Buffer
+	framing := testFraming(t, "rfc3339", "[{{.Job}}.{{.Instance}} {{.Pid}} {{.Stream}}] ")
+	out := framing.Writer(&buf, func() Frame { return Frame{3, 1, 42, "stdout"} })
+	err := framing.Writer(&buf, func() Frame { return Frame{3, 1, 42, "stderr"} })
+	out.Write([]byte("first\nsec"))
+	err.Write([]byte("failed\r\n"))
+	out.Write([]byte("ond\nlast"))
+	out.Flush()
+	expected := "2020-01-01T10:21:33.500Z [3.1 42 stdout] first\n" +
+		"2020-01-01T10:21:33.500Z [3.1 42 stderr] failed\n" +
+		"2020-01-01T10:21:33.500Z [3.1 42 stdout] second\n" +
+		"2020-01-01T10:21:33.500Z [3.1 42 stdout] last\n"
+	if buf.String() != expected {
+		t.Errorf("Framing should frame whole lines:\n%q\n%q", buf.String(), expected)
+	}
+}
+
+func TestOutputFramingUnix(t *testing.T) {
+	var buf bytes.Buffer
+	framing := testFraming(t, "unix", "")
+	framing.Writer(&buf, func() Frame { return Frame{} }).Write([]byte("up\n"))
+	if buf.String() != "1577874093.500 up\n" {
+		t.Errorf("Framing should stamp lines with unix time: %q", buf.String())
+	}
+}
+
+func TestOutputFramingInvalid(t *testing.T) {
+	if _, err := NewFraming("iso", ""); err == nil {
+		t.Errorf("NewFraming should reject unknown timestamps")
+	}
+	if _, err := NewFraming("", "{{.Host}}"); err == nil {
+		t.Errorf("NewFraming should reject unknown fields")
+	}
+	if framing, _ := NewFraming("none", ""); framing.Enabled() {
+		t.Errorf("Framing without timestamps or prefix should be disabled")
+	}
+}
+
+func TestOutputLineWriterLongLines(t *testing.T) {
+	var lines []int
+	w := NewLineWriter(func(line []byte) { lines = append(lines, len(line)) })
+	chunk := []byte(strings.Repeat("x", 1000))
+	for n := 0; n < 2*MAXLINE/len(chunk)+1; n++ {
+		w.Write(chunk)
+	}
+	if len(w.partial) >= MAXLINE || len(lines) != 2 {
+		t.Errorf("LineWriter should bound the partial line: %d %v", len(w.partial), lines)
+	}
+	w.Write(append(bytes.Repeat([]byte("y"), 2*MAXLINE), '\n'))
+	w.Flush()
+	for _, n := range lines {
+		if n > MAXLINE {
+			t.Errorf("LineWriter should split long lines: %v", lines)
+		}
+	}
+}
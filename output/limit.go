@@ -0,0 +1,230 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+/*
+ * MARKERINTERVAL is the least time between markers counting the lines
+ * suppressed, so that a flood of output yields a marker a second
+ */
+const MARKERINTERVAL = time.Second
+
+/*
+ * Limiter bounds the output of a process, shared by its stdout & stderr:
+ * lines beyond Rate a second, after a burst of Burst lines, are suppressed,
+ * & lines are cut after MaxLineLength bytes. Zero values disable a limit
+ */
+type Limiter struct {
+	Rate          int
+	Burst         int
+	MaxLineLength int
+	tokens        float64
+	last          time.Time
+	pending       int
+	marked        time.Time
+	suppressed    int64
+	truncated     int64
+	now           func() time.Time
+	lock          sync.Mutex
+}
+
+/*
+ * NewLimiter creates a Limiter allowing rate lines a second, burst at once,
+ * rate if burst is 0, & lines of up to maxLineLength bytes
+ */
+func NewLimiter(rate, burst, maxLineLength int) *Limiter {
+	if burst == 0 {
+		burst = rate
+	}
+	return &Limiter{
+		Rate:          rate,
+		Burst:         burst,
+		MaxLineLength: maxLineLength,
+		tokens:        float64(burst),
+		now:           time.Now,
+	}
+}
+
+/*
+ * Suppressed returns the number of lines suppressed
+ */
+func (l *Limiter) Suppressed() int64 {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	return l.suppressed
+}
+
+/*
+ * Truncated returns the number of lines cut to MaxLineLength
+ */
+func (l *Limiter) Truncated() int64 {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	return l.truncated
+}
+
+/*
+ * allow decides whether a line starting now is written, returning with it
+ * the number of lines suppressed to mark before it, 0 if it is too soon
+ * since the last marker
+ */
+func (l *Limiter) allow() (bool, int) {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	if l.Rate == 0 {
+		return true, 0
+	}
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.Rate)
+		if l.tokens > float64(l.Burst) {
+			l.tokens = float64(l.Burst)
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		l.pending++
+		l.suppressed++
+		return false, 0
+	}
+	l.tokens--
+	if l.pending == 0 || now.Sub(l.marked) < MARKERINTERVAL {
+		return true, 0
+	}
+	pending := l.pending
+	l.pending, l.marked = 0, now
+	return true, pending
+}
+
+/*
+ * unmarked returns the number of lines suppressed since the last marker, to
+ * be marked once the process exits
+ */
+func (l *Limiter) unmarked() int {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	pending := l.pending
+	l.pending, l.marked = 0, l.now()
+	return pending
+}
+
+func (l *Limiter) countTruncated() {
+	defer l.lock.Unlock()
+	l.lock.Lock()
+	l.truncated++
+}
+
+/*
+ * LimitWriter applies a Limiter to a stream, passing what it allows on to w
+ * as it is written rather than holding back partial lines, so output such
+ * as prompts is not delayed
+ */
+type LimitWriter struct {
+	limiter *Limiter
+	w       io.Writer
+	midline bool
+	drop    bool
+	length  int
+	cut     int
+	lock    sync.Mutex
+}
+
+/*
+ * Writer creates a LimitWriter limiting a stream written to w
+ */
+func (l *Limiter) Writer(w io.Writer) *LimitWriter {
+	return &LimitWriter{limiter: l, w: w}
+}
+
+/*
+ * Write passes on the allowed part of p, marking where lines were
+ * suppressed or cut. It never fails, as the process should not be stopped
+ * by its output being limited
+ */
+func (w *LimitWriter) Write(p []byte) (int, error) {
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	n, out := len(p), []byte{}
+	for len(p) > 0 {
+		if !w.midline {
+			allowed, pending := w.limiter.allow()
+			out = append(out, suppressedMarker(pending)...)
+			w.midline, w.drop, w.length, w.cut = true, !allowed, 0, 0
+		}
+		line, end := p, bytes.IndexByte(p, '\n')
+		if end != -1 {
+			line = p[:end]
+		}
+		if !w.drop {
+			keep := len(line)
+			if max := w.limiter.MaxLineLength; max > 0 && w.length+keep > max {
+				keep = max - w.length
+			}
+			out = append(out, line[:keep]...)
+			w.length += keep
+			w.cut += len(line) - keep
+		}
+		if end == -1 {
+			break
+		}
+		if !w.drop {
+			out = append(out, w.endLine()...)
+		}
+		w.midline, p = false, p[end+1:]
+	}
+	if len(out) != 0 {
+		w.w.Write(out)
+	}
+	return n, nil
+}
+
+/*
+ * Flush notes the bytes cut from a partial line & marks the lines
+ * suppressed since the last marker, once the process exits
+ */
+func (w *LimitWriter) Flush() {
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	out, pending := []byte{}, w.limiter.unmarked()
+	// a partial line is left as it is, unless it was cut or is followed by
+	// a marker
+	if w.midline && !w.drop && (w.cut > 0 || pending > 0) {
+		out = append(out, w.endLine()...)
+	}
+	w.midline = false
+	out = append(out, suppressedMarker(pending)...)
+	if len(out) != 0 {
+		w.w.Write(out)
+	}
+}
+
+/*
+ * endLine returns the end of an allowed line, noting the bytes cut from it
+ */
+func (w *LimitWriter) endLine() []byte {
+	if w.cut == 0 {
+		return []byte{'\n'}
+	}
+	w.limiter.countTruncated()
+	return []byte(fmt.Sprintf(" [taskmaster: %d bytes truncated]\n", w.cut))
+}
+
+/*
+ * suppressedMarker returns the line marking where lines were suppressed,
+ * empty if there were none
+ */
+func suppressedMarker(pending int) []byte {
+	if pending == 0 {
+		return nil
+	}
+	lines := "lines"
+	if pending == 1 {
+		lines = "line"
+	}
+	return []byte(fmt.Sprintf("[taskmaster: %d %s suppressed]\n", pending, lines))
+}
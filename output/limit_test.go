@@ -0,0 +1,71 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLimiter(rate, burst, maxLineLength int) (*Limiter, *time.Time) {
+	now := time.Date(2020, 1, 1, 10, 21, 33, 0, time.UTC)
+	l := NewLimiter(rate, burst, maxLineLength)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestOutputLimiterSuppressesLines(t *testing.T) {
+	var buf bytes.Buffer
+	l, now := testLimiter(2, 3, 0)
+	w := l.Writer(&buf)
+	w.Write([]byte("1\n2\n3\n4\n5\n"))
+	*now = now.Add(500 * time.Millisecond)
+	w.Write([]byte("6\n7\n"))
+	*now = now.Add(MARKERINTERVAL)
+	w.Write([]byte("8\n"))
+	expected := "1\n2\n3\n[taskmaster: 2 lines suppressed]\n6\n" +
+		"[taskmaster: 1 line suppressed]\n8\n"
+	if buf.String() != expected {
+		t.Errorf("Limiter should suppress lines past its rate:\n%q\n%q", buf.String(), expected)
+	}
+	if l.Suppressed() != 3 {
+		t.Errorf("Limiter should count suppressed lines: %d", l.Suppressed())
+	}
+}
+
+func TestOutputLimiterMarksOnFlush(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := testLimiter(1, 1, 0)
+	out, err := l.Writer(&buf), l.Writer(&buf)
+	out.Write([]byte("up\nsecond\n"))
+	err.Write([]byte("failed\nprompt> "))
+	out.Flush()
+	err.Flush()
+	expected := "up\n[taskmaster: 3 lines suppressed]\n"
+	if buf.String() != expected {
+		t.Errorf("Limiter should share its rate & mark lines on exit:\n%q\n%q", buf.String(), expected)
+	}
+}
+
+func TestOutputLimiterTruncatesLines(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := testLimiter(0, 0, 8)
+	w := l.Writer(&buf)
+	w.Write([]byte("short\n0123"))
+	w.Write([]byte("456789abc"))
+	w.Write([]byte("def\nprompt> "))
+	w.Flush()
+	expected := "short\n01234567 [taskmaster: 8 bytes truncated]\nprompt> "
+	if buf.String() != expected {
+		t.Errorf("Limiter should cut long lines:\n%q\n%q", buf.String(), expected)
+	}
+	buf.Reset()
+	w.Write([]byte(strings.Repeat("x", 10)))
+	w.Flush()
+	if buf.String() != "xxxxxxxx [taskmaster: 2 bytes truncated]\n" {
+		t.Errorf("Limiter should note bytes cut from a partial line: %q", buf.String())
+	}
+	if l.Truncated() != 2 {
+		t.Errorf("Limiter should count truncated lines: %d", l.Truncated())
+	}
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"bytes"
+	"sync"
+)
+
+/*
+ * MAXLINE bounds the partial line a LineWriter holds back, longer lines
+ * being emitted in pieces of MAXLINE bytes
+ */
+const MAXLINE = 64 << 10
+
+/*
+ * LineWriter splits the output of a stream into lines, passing each to
+ * emit without its line ending & holding back a partial line until it is
+ * completed or flushed
+ */
+type LineWriter struct {
+	emit    func(line []byte)
+	partial []byte
+	lock    sync.Mutex
+}
+
+/*
+ * NewLineWriter creates a LineWriter passing lines to emit
+ */
+func NewLineWriter(emit func(line []byte)) *LineWriter {
+	return &LineWriter{emit: emit}
+}
+
+/*
+ * Write emits each complete line of p. It never fails, as a destination
+ * which cannot be written to should not stop output being captured
+ */
+func (w *LineWriter) Write(p []byte) (int, error) {
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	w.partial = append(w.partial, p...)
+	for {
+		end := bytes.IndexByte(w.partial, '\n')
+		if end == -1 && len(w.partial) < MAXLINE {
+			break
+		} else if end == -1 || end > MAXLINE {
+			w.emit(w.partial[:MAXLINE])
+			w.partial = w.partial[MAXLINE:]
+			continue
+		}
+		w.emit(bytes.TrimSuffix(w.partial[:end], []byte("\r")))
+		w.partial = w.partial[end+1:]
+	}
+	if len(w.partial) == 0 {
+		w.partial = nil
+	}
+	return len(p), nil
+}
+
+/*
+ * Flush emits the partial line held back, such as once the process exits
+ * without ending its last line
+ */
+func (w *LineWriter) Flush() {
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	if len(w.partial) != 0 {
+		w.emit(bytes.TrimSuffix(w.partial, []byte("\r")))
+		w.partial = nil
+	}
+}
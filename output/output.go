@@ -0,0 +1,177 @@
+package output
+
+import (
+	"io"
+	"sync"
+)
+
+/*
+ * CLIENTQUEUE is the number of writes queued for a client before it is
+ * considered too slow and detached
+ */
+const CLIENTQUEUE = 64
+
+/*
+ * DROPPEDMSG is written to a client once it is detached for being too slow
+ */
+const DROPPEDMSG = "\n[taskmaster: detached, output was not read fast enough]\n"
+
+/*
+ * Broadcaster copies a process's output to its sink, the file it is
+ * redirected to if any, and to every writer attached to it
+ */
+type Broadcaster struct {
+	sink     io.Writer
+	attached map[int]io.Writer
+	clients  map[int]*client
+	next     int
+	lock     sync.Mutex
+}
+
+/*
+ * client holds the output queued for a writer outside of taskmaster, such
+ * as a control socket connection, & whether it was detached for being slow
+ */
+type client struct {
+	queue chan []byte
+	slow  bool
+}
+
+/*
+ * NewBroadcaster creates a Broadcaster writing to sink, which may be nil to
+ * discard output nobody is attached to
+ */
+func NewBroadcaster(sink io.Writer) *Broadcaster {
+	return &Broadcaster{
+		sink:     sink,
+		attached: make(map[int]io.Writer),
+		clients:  make(map[int]*client),
+	}
+}
+
+/*
+ * SetSink replaces the sink, such as with the log file of a new process
+ */
+func (b *Broadcaster) SetSink(sink io.Writer) {
+	defer b.lock.Unlock()
+	b.lock.Lock()
+	b.sink = sink
+}
+
+/*
+ * Write copies p to the sink and every attached writer, detaching writers
+ * which fail so that a closed connection cannot block the process. Clients
+ * are only queued a copy of p, & detached once their queue is full
+ */
+func (b *Broadcaster) Write(p []byte) (int, error) {
+	defer b.lock.Unlock()
+	b.lock.Lock()
+	var err error
+	if b.sink != nil {
+		_, err = b.sink.Write(p)
+	}
+	for id, w := range b.attached {
+		if _, e := w.Write(p); e != nil {
+			delete(b.attached, id)
+		}
+	}
+	if len(b.clients) > 0 {
+		chunk := append([]byte(nil), p...)
+		for id, c := range b.clients {
+			select {
+			case c.queue <- chunk:
+			default:
+				c.slow = true
+				b.removeClient(id)
+			}
+		}
+	}
+	return len(p), err
+}
+
+/*
+ * Attach starts copying output to w, returning a function which stops it.
+ * w is written while the process's output is being read, so it must not
+ * block, as with the line writers of taskmaster itself
+ */
+func (b *Broadcaster) Attach(w io.Writer) func() {
+	defer b.lock.Unlock()
+	b.lock.Lock()
+	id := b.next
+	b.next++
+	b.attached[id] = w
+	return func() {
+		b.lock.Lock()
+		delete(b.attached, id)
+		b.lock.Unlock()
+	}
+}
+
+/*
+ * AttachClient starts copying output to w from its own goroutine, returning
+ * a function which stops it. Should w fall CLIENTQUEUE writes behind it is
+ * detached, written DROPPEDMSG once its queued output is written, & dropped
+ * is called if not nil
+ */
+func (b *Broadcaster) AttachClient(w io.Writer, dropped func()) func() {
+	c := &client{queue: make(chan []byte, CLIENTQUEUE)}
+	b.lock.Lock()
+	id := b.next
+	b.next++
+	b.clients[id] = c
+	b.lock.Unlock()
+	detach := func() {
+		b.lock.Lock()
+		b.removeClient(id)
+		b.lock.Unlock()
+	}
+	go func() {
+		for p := range c.queue {
+			if _, err := w.Write(p); err != nil {
+				detach()
+				return
+			}
+		}
+		b.lock.Lock()
+		slow := c.slow
+		b.lock.Unlock()
+		if slow {
+			w.Write([]byte(DROPPEDMSG))
+			if dropped != nil {
+				dropped()
+			}
+		}
+	}()
+	return detach
+}
+
+/*
+ * removeClient stops queueing output for the client, which must be called
+ * with the lock held
+ */
+func (b *Broadcaster) removeClient(id int) {
+	if c, ok := b.clients[id]; ok {
+		delete(b.clients, id)
+		close(c.queue)
+	}
+}
+
+/*
+ * Capture copies r to w until r is exhausted, closing done once finished.
+ * Errors writing to w are ignored, as the process would block once it
+ * filled the pipe if r stopped being read
+ */
+func Capture(r io.ReadCloser, w io.Writer, done chan struct{}) {
+	defer close(done)
+	defer r.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestOutputBroadcasterCopiesToAttached(t *testing.T) {
+	var sink, attached bytes.Buffer
+	b := NewBroadcaster(&sink)
+	b.Write([]byte("before "))
+	detach := b.Attach(&attached)
+	b.Write([]byte("during "))
+	detach()
+	b.Write([]byte("after"))
+	if sink.String() != "before during after" {
+		t.Errorf("Broadcaster should write everything to its sink: %q", sink.String())
+	} else if attached.String() != "during " {
+		t.Errorf("Broadcaster should write to attached writers: %q", attached.String())
+	}
+}
+
+func TestOutputBroadcasterDetachesFailingWriters(t *testing.T) {
+	var attached bytes.Buffer
+	b := NewBroadcaster(nil)
+	b.Attach(failingWriter{})
+	b.Attach(&attached)
+	if n, err := b.Write([]byte("out")); n != 3 || err != nil {
+		t.Errorf("failing attached writers should not fail the write: %d %v", n, err)
+	} else if len(b.attached) != 1 || attached.String() != "out" {
+		t.Errorf("failing attached writers should be detached: %v", b.attached)
+	}
+}
+
+/*
+ * stalledWriter is a client which never reads its output until released
+ */
+type stalledWriter struct {
+	release chan struct{}
+	lock    sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	<-w.release
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	return w.buf.Write(p)
+}
+
+func (w *stalledWriter) String() string {
+	defer w.lock.Unlock()
+	w.lock.Lock()
+	return w.buf.String()
+}
+
+func TestOutputBroadcasterCopiesToClients(t *testing.T) {
+	w := &stalledWriter{release: make(chan struct{})}
+	close(w.release)
+	b := NewBroadcaster(nil)
+	detach := b.AttachClient(w, nil)
+	b.Write([]byte("one "))
+	b.Write([]byte("two"))
+	for end := time.Now().Add(time.Second); time.Now().Before(end); {
+		if w.String() == "one two" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	detach()
+	b.Write([]byte(" three"))
+	time.Sleep(10 * time.Millisecond)
+	if w.String() != "one two" {
+		t.Errorf("Broadcaster should copy to clients until detached: %q", w.String())
+	}
+}
+
+func TestOutputBroadcasterDetachesSlowClients(t *testing.T) {
+	var sink bytes.Buffer
+	w := &stalledWriter{release: make(chan struct{})}
+	dropped := make(chan struct{})
+	b := NewBroadcaster(&sink)
+	b.AttachClient(w, func() { close(dropped) })
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for n := 0; n < CLIENTQUEUE+2; n++ {
+			b.Write([]byte("x"))
+		}
+	}()
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatalf("a client which never reads should not block the process")
+	}
+	b.lock.Lock()
+	clients := len(b.clients)
+	b.lock.Unlock()
+	if clients != 0 {
+		t.Errorf("a client which never reads should be detached")
+	} else if sink.Len() != CLIENTQUEUE+2 {
+		t.Errorf("the sink should receive all output: %d bytes", sink.Len())
+	}
+	close(w.release)
+	select {
+	case <-dropped:
+		if !strings.HasSuffix(w.String(), DROPPEDMSG) {
+			t.Errorf("a slow client should be told it was detached: %q", w.String())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("dropped should be called once a slow client is detached")
+	}
+}
+
+func TestOutputCapture(t *testing.T) {
+	var sink bytes.Buffer
+	done := make(chan struct{})
+	Capture(ioutil.NopCloser(strings.NewReader("line\n")), &sink, done)
+	select {
+	case <-done:
+		if sink.String() != "line\n" {
+			t.Errorf("Capture should copy the reader: %q", sink.String())
+		}
+	default:
+		t.Errorf("Capture should close done once finished")
+	}
+}
+
+func TestOutputCaptureIgnoresSinkErrors(t *testing.T) {
+	done := make(chan struct{})
+	r := strings.NewReader(strings.Repeat("x", 64*1024))
+	Capture(ioutil.NopCloser(r), failingWriter{}, done)
+	if r.Len() != 0 {
+		t.Errorf("Capture should keep reading after the sink fails: %d left", r.Len())
+	}
+}
@@ -0,0 +1,89 @@
+package output
+
+import "sync"
+
+/*
+ * Ring keeps the most recent lines of a process's output in memory, up to
+ * MaxLines lines & MaxBytes bytes, so they can be shown without redirecting
+ * output to a file
+ */
+type Ring struct {
+	MaxLines int
+	MaxBytes int
+	lines    []string
+	size     int
+	total    int
+	lock     sync.Mutex
+}
+
+/*
+ * NewRing creates a Ring holding up to maxLines lines & maxBytes bytes
+ */
+func NewRing(maxLines, maxBytes int) *Ring {
+	return &Ring{MaxLines: maxLines, MaxBytes: maxBytes}
+}
+
+/*
+ * Source returns a writer adding the lines written to it to the ring, each
+ * stream of the process needing its own to keep partial lines apart
+ */
+func (r *Ring) Source() *LineWriter {
+	return NewLineWriter(r.add)
+}
+
+/*
+ * add appends a line, truncated to its last MaxBytes bytes, dropping the
+ * oldest lines once over either limit
+ */
+func (r *Ring) add(line []byte) {
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	if r.MaxBytes > 0 && len(line) > r.MaxBytes {
+		line = line[len(line)-r.MaxBytes:]
+	}
+	r.lines = append(r.lines, string(line))
+	r.size += len(line)
+	r.total++
+	for len(r.lines) > 0 && (r.MaxLines > 0 && len(r.lines) > r.MaxLines ||
+		r.MaxBytes > 0 && r.size > r.MaxBytes) {
+		r.size -= len(r.lines[0])
+		r.lines = r.lines[1:]
+	}
+}
+
+/*
+ * Total returns the number of lines ever added, marking a point from which
+ * Since returns the lines added
+ */
+func (r *Ring) Total() int {
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	return r.total
+}
+
+/*
+ * Lines returns up to the last n lines held, or all of them if n is 0
+ */
+func (r *Ring) Lines(n int) []string {
+	return r.Since(0, n)
+}
+
+/*
+ * Since returns up to the last n lines held which were added after mark, a
+ * value returned by Total, or all of them if n is 0
+ */
+func (r *Ring) Since(mark, n int) []string {
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	held := len(r.lines)
+	if added := r.total - mark; added < held {
+		held = added
+	}
+	if n > 0 && n < held {
+		held = n
+	}
+	if held <= 0 {
+		return nil
+	}
+	return append([]string{}, r.lines[len(r.lines)-held:]...)
+}
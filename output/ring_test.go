@@ -0,0 +1,55 @@
+package output
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutputRingKeepsLastLines(t *testing.T) {
+	r := NewRing(3, 0)
+	src := r.Source()
+	src.Write([]byte("1\n2\n3\n4\n5\n"))
+	if lines := r.Lines(0); !reflect.DeepEqual(lines, []string{"3", "4", "5"}) {
+		t.Errorf("Ring should keep the last 3 lines: %q", lines)
+	}
+	if lines := r.Lines(2); !reflect.DeepEqual(lines, []string{"4", "5"}) {
+		t.Errorf("Ring should return the last 2 lines: %q", lines)
+	}
+	if r.Total() != 5 {
+		t.Errorf("Ring should count every line added: %d", r.Total())
+	}
+}
+
+func TestOutputRingMaxBytes(t *testing.T) {
+	r := NewRing(0, 8)
+	src := r.Source()
+	src.Write([]byte("abc\ndef\nghi\n"))
+	if lines := r.Lines(0); !reflect.DeepEqual(lines, []string{"def", "ghi"}) {
+		t.Errorf("Ring should drop lines over its byte limit: %q", lines)
+	}
+	src.Write([]byte(strings.Repeat("x", 4) + "0123456789\n"))
+	if lines := r.Lines(0); !reflect.DeepEqual(lines, []string{"23456789"}) {
+		t.Errorf("Ring should keep the end of overlong lines: %q", lines)
+	}
+}
+
+func TestOutputRingSince(t *testing.T) {
+	r := NewRing(10, 0)
+	out, err := r.Source(), r.Source()
+	out.Write([]byte("old\n"))
+	mark := r.Total()
+	if lines := r.Since(mark, 0); lines != nil {
+		t.Errorf("Ring should have nothing since mark: %q", lines)
+	}
+	out.Write([]byte("new "))
+	err.Write([]byte("failed\n"))
+	out.Write([]byte("line\n"))
+	expected := []string{"failed", "new line"}
+	if lines := r.Since(mark, 0); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Ring should return lines since mark: %q %q", lines, expected)
+	}
+	if lines := r.Since(mark, 1); !reflect.DeepEqual(lines, expected[1:]) {
+		t.Errorf("Ring should limit lines since mark: %q", lines)
+	}
+}
@@ -0,0 +1,179 @@
+package output
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+ * Rotation configures when a log file is rotated & how many of the rotated
+ * files are kept, MaxBytes of 0 disabling rotation
+ */
+type Rotation struct {
+	MaxBytes int64
+	Backups  int
+	Compress bool
+}
+
+/*
+ * RotatingFile appends to a log file, renaming it to Path.1 once writing
+ * would grow it past MaxBytes & shifting older backups to Path.2 onwards,
+ * with the backups optionally gzipped to Path.N.gz
+ */
+type RotatingFile struct {
+	Path string
+	Rotation
+	file *os.File
+	size int64
+	lock sync.Mutex
+}
+
+/*
+ * OpenRotating opens the log file at path for appending, creating it if
+ * needed
+ */
+func OpenRotating(path string, rotation Rotation) (*RotatingFile, error) {
+	r := &RotatingFile{Path: path, Rotation: rotation}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+ * open opens the log file & records its current size
+ */
+func (r *RotatingFile) open() error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	f, err := os.OpenFile(r.Path, flags, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+/*
+ * Write appends p to the log file, rotating it first if p would grow it past
+ * MaxBytes
+ */
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.MaxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+/*
+ * rotate shifts the backups along, dropping the oldest, moves the log file to
+ * the first backup & reopens it empty. Without backups the log file is
+ * truncated instead
+ */
+func (r *RotatingFile) rotate() error {
+	if r.Backups == 0 {
+		if err := r.file.Truncate(0); err != nil {
+			return err
+		}
+		r.size = 0
+		return nil
+	}
+	r.file.Close()
+	r.file = nil
+	for _, ext := range []string{"", ".gz"} {
+		os.Remove(r.backup(r.Backups) + ext)
+		for n := r.Backups - 1; n >= 1; n-- {
+			os.Rename(r.backup(n)+ext, r.backup(n+1)+ext)
+		}
+	}
+	if err := os.Rename(r.Path, r.backup(1)); err != nil {
+		return err
+	} else if r.Compress {
+		if err := compress(r.backup(1)); err != nil {
+			return err
+		}
+	}
+	return r.open()
+}
+
+/*
+ * backup returns the name of the nth backup, without its compression suffix
+ */
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.Path, n)
+}
+
+/*
+ * compress gzips the file at path to path.gz, removing the original
+ */
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+/*
+ * Reopen reopens the log file at Path, picking up the file created in its
+ * place after logrotate or another tool moved it away. The old file is only
+ * closed once the new one is open, so no write is lost
+ */
+func (r *RotatingFile) Reopen() error {
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	old := r.file
+	if err := r.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+/*
+ * Close closes the log file, after which writes fail
+ */
+func (r *RotatingFile) Close() error {
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
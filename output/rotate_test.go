@@ -0,0 +1,116 @@
+package output
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	ioutil.WriteFile(path, []byte("previous\n"), 0644)
+	r, err := OpenRotating(path, Rotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("next\n"))
+	r.Close()
+	if contents := readFile(t, path); contents != "previous\nnext\n" {
+		t.Errorf("RotatingFile should append to the log: %q", contents)
+	} else if _, err := r.Write([]byte("closed")); err == nil {
+		t.Errorf("RotatingFile should fail writes once closed")
+	}
+}
+
+func TestRotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	r, err := OpenRotating(path, Rotation{MaxBytes: 10, Backups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		r.Write([]byte(line))
+	}
+	if contents := readFile(t, path); contents != "fourth\n" {
+		t.Errorf("log should hold the latest write: %q", contents)
+	} else if contents := readFile(t, path+".1"); contents != "third\n" {
+		t.Errorf("first backup should hold the previous write: %q", contents)
+	} else if contents := readFile(t, path+".2"); contents != "second\n" {
+		t.Errorf("second backup should hold the write before: %q", contents)
+	} else if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backups beyond the limit should be removed: %v", err)
+	}
+}
+
+func TestRotatingFileTruncatesWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	r, err := OpenRotating(path, Rotation{MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Write([]byte("first\n"))
+	r.Write([]byte("second\n"))
+	if contents := readFile(t, path); contents != "second\n" {
+		t.Errorf("log should be truncated: %q", contents)
+	} else if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("no backup should be kept: %v", err)
+	}
+}
+
+func TestRotatingFileCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	r, err := OpenRotating(path, Rotation{MaxBytes: 10, Backups: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Write([]byte("first\n"))
+	r.Write([]byte("second\n"))
+	f, err := os.Open(path + ".1.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents, _ := ioutil.ReadAll(zr); string(contents) != "first\n" {
+		t.Errorf("backup should be compressed: %q", contents)
+	} else if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("uncompressed backup should be removed: %v", err)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	r, err := OpenRotating(path, Rotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Write([]byte("before\n"))
+	os.Rename(path, path+".rotated")
+	r.Write([]byte("moved\n"))
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("after\n"))
+	if contents := readFile(t, path+".rotated"); contents != "before\nmoved\n" {
+		t.Errorf("RotatingFile should write to the moved file until reopened: %q", contents)
+	} else if contents := readFile(t, path); contents != "after\n" {
+		t.Errorf("RotatingFile should write to a new file once reopened: %q", contents)
+	}
+}
@@ -0,0 +1,67 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+/*
+ * Tail returns the last n lines of the file at path, reading it backwards
+ * so that large log files are not read whole
+ */
+func Tail(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := info.Size()
+	var tail []byte
+	chunk := make([]byte, 4096)
+	for offset := end; offset > 0 && n > 0; {
+		size := int64(len(chunk))
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		if _, err := f.ReadAt(chunk[:size], offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(append([]byte{}, chunk[:size]...), tail...)
+		// the newline ending the last line does not start a new one
+		lines := bytes.Count(tail, []byte("\n"))
+		if tail[len(tail)-1] != '\n' {
+			lines++
+		}
+		if lines > n {
+			break
+		}
+	}
+	return lastLines(tail, n), nil
+}
+
+/*
+ * lastLines returns the last n lines of buf
+ */
+func lastLines(buf []byte, n int) []byte {
+	if n <= 0 || len(buf) == 0 {
+		return nil
+	}
+	end := len(buf)
+	if buf[end-1] == '\n' {
+		end--
+	}
+	for start := end - 1; start >= 0; start-- {
+		if buf[start] == '\n' {
+			if n--; n == 0 {
+				return buf[start+1:]
+			}
+		}
+	}
+	return buf
+}
@@ -0,0 +1,44 @@
+package output
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	var lines []string
+	for n := 0; n < 2000; n++ {
+		lines = append(lines, fmt.Sprintf("line %d", n))
+	}
+	ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	cases := map[int]string{
+		0:    "",
+		1:    "line 1999\n",
+		3:    "line 1997\nline 1998\nline 1999\n",
+		2000: strings.Join(lines, "\n") + "\n",
+		5000: strings.Join(lines, "\n") + "\n",
+	}
+	for n, expected := range cases {
+		if tail, err := Tail(path, n); err != nil {
+			t.Errorf("%d: %v", n, err)
+		} else if string(tail) != expected {
+			t.Errorf("%d: expected %d bytes, received %d", n, len(expected), len(tail))
+		}
+	}
+}
+
+func TestOutputTailUnterminated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	ioutil.WriteFile(path, []byte("one\ntwo\nthree"), 0644)
+	if tail, err := Tail(path, 2); err != nil || string(tail) != "two\nthree" {
+		t.Errorf("Tail should count an unterminated last line: %q %v", tail, err)
+	}
+	ioutil.WriteFile(path, nil, 0644)
+	if tail, err := Tail(path, 2); err != nil || len(tail) != 0 {
+		t.Errorf("Tail of an empty file should be empty: %q %v", tail, err)
+	}
+}
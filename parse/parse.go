@@ -3,16 +3,22 @@ package parse
 import (
 	"fmt"
 	"io/ioutil"
+	"log/syslog"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	CFG "github.com/Travmatth/taskmaster/config"
 	INST "github.com/Travmatth/taskmaster/instance"
 	JOB "github.com/Travmatth/taskmaster/job"
 	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/output"
+	"github.com/Travmatth/taskmaster/pty"
+	SCHED "github.com/Travmatth/taskmaster/schedule"
 	SIG "github.com/Travmatth/taskmaster/signals"
 	"gopkg.in/oleiade/reflections.v1"
 	"gopkg.in/yaml.v2"
@@ -26,13 +32,26 @@ const (
 	STARTCHECKUPMSG = "Error: invalid startCheckup value: %s\n"
 	STOPTIMEOUTMSG  = "Error: invalid StopTimeout value: %s\n"
 	UMASKMSG        = "Error: invalid umask value: %s\n"
+	STOPSEQUENCEMSG = "Configuration error: invalid stopSequence step %d for %v: %s"
+	TYPEMSG         = "Error: Type for %v must be one of: service | oneshot, recieved: \"%s\""
+	DEPENDSONMSG    = "Configuration error: invalid dependsOn for %v: %s"
+	SOCKETMSG       = "Configuration error: invalid socket %d for %v: %s"
+	OVERLAPMSG      = "Error: Overlap Policy for %v must be one of: skip | queue | replace, recieved: \"%s\""
+	WATCHMSG        = "Configuration error: invalid watch for %v: %s"
+	WATCHDOGMSG     = "Configuration error: invalid %s for %v: %s"
+	INVALIDMSG      = "Configuration error: invalid %s for %v: %s"
+	COMMANDMSG      = "Configuration error: missing command for %v"
+	ROTATIONMSG     = "Configuration error: invalid logRotation %s for %v: %s"
 )
 
+// ConsoleOutput shows the output of jobs which do not set console on
+// taskmaster's stdout, set by --console
+var ConsoleOutput bool
+
 // Flags used in OpenRedir
 const (
 	stdinFlags  = os.O_CREATE | os.O_RDONLY
-	stdoutFlags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
-	stderrFlags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	stdoutFlags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
 )
 
 //ConfigureInstance parse configuration file to set Instance struct properties
@@ -40,6 +59,9 @@ func ConfigureInstance(c CFG.JobConfig,
 	instance *INST.Instance, umask int) error {
 	// The command to use to launch the program
 	instance.Args = strings.Fields(c.Command)
+	if len(instance.Args) == 0 {
+		return fmt.Errorf(COMMANDMSG, c)
+	}
 	// Whether the program should restart always, never, or unexpected exits
 	switch strings.ToLower(c.RestartPolicy) {
 	case "always":
@@ -71,26 +93,128 @@ func ConfigureInstance(c CFG.JobConfig,
 	// signal used to stop (instance.e. exit gracefully) the program
 	if c.StopSignal == "" {
 		instance.StopSignal = syscall.Signal(0)
-	} else if sig, ok := SIG.Signals[strings.ToUpper(c.StopSignal)]; ok {
+	} else if sig, err := SIG.Parse(c.StopSignal); err == nil {
 		instance.StopSignal = sig
 	} else {
 		return fmt.Errorf("Configuration error: invalid stop signal for %v", c)
 	}
 	// How long to wait after a graceful stop before killing the program
 	ParseInt(c, instance, "StopTimeout", STOPTIMEOUTMSG, 1)
-	// Options to discard stdout/stderr or to redirect them to files
+	// Signals to escalate through when stopping, overrides stopSignal
+	if sequence, err := ParseStopSequence(c); err != nil {
+		return err
+	} else {
+		instance.StopSequence = sequence
+	}
+	// Resource limits which restart the process when exceeded
+	if watchdog, err := ParseWatchdog(c); err != nil {
+		return err
+	} else {
+		instance.Watchdog = watchdog
+	}
+	// How long the process may run before it is stopped as timed out
+	if c.MaxRuntime != "" {
+		if val, err := ParseDuration(c.MaxRuntime); err != nil || val <= 0 {
+			return fmt.Errorf(INVALIDMSG, "maxRuntime", c, c.MaxRuntime)
+		} else {
+			instance.MaxRuntime = val
+		}
+	}
+	// Options to discard stdout/stderr or to redirect them to files, which
+	// are captured through pipes & written to a log file per instance
 	in := c.Redirections.Stdin
-	out := c.Redirections.Stdout
-	serr := c.Redirections.Stderr
+	out := LogPath(c, instance.InstanceID, c.Redirections.Stdout)
+	serr := LogPath(c, instance.InstanceID, c.Redirections.Stderr)
 	if stdin, err := OpenRedir(in, stdinFlags); err != nil {
 		return err
-	} else if stdout, err := OpenRedir(out, stdoutFlags); err != nil {
+	} else if err := CheckRedir(out); err != nil {
 		return err
-	} else if stderr, err := OpenRedir(serr, stdoutFlags); err != nil {
+	} else if err := CheckRedir(serr); err != nil {
 		return err
 	} else {
-		instance.Redirections = []*os.File{stdin, stdout, stderr}
+		instance.Redirections = []*os.File{stdin, nil, nil}
+		instance.LogFiles = []string{out, serr}
+	}
+	if rotation, err := ParseRotation(c); err != nil {
+		return err
+	} else {
+		instance.Rotation = rotation
+	}
+	if framing, err := ParseFraming(c); err != nil {
+		return err
+	} else {
+		instance.Framing = framing
+	}
+	if out != "" || serr != "" {
+		captureOutput(instance)
+	}
+	// Whether operators may attach to the process's input & output, which
+	// are then piped through taskmaster
+	switch strings.ToLower(c.Interactive) {
+	case "true":
+		if in != "" {
+			return fmt.Errorf("%v configuration error: interactive jobs read stdin from attach", c)
+		}
+		instance.Interactive = true
+		captureOutput(instance)
+	case "false", "":
+		instance.Interactive = false
+	default:
+		return fmt.Errorf("%v configuration error: invalid value for interactive", c)
+	}
+	// Whether the process runs on a pseudo-terminal, whose output is written
+	// to the stdout redirection & which operators may attach to
+	switch strings.ToLower(c.TTY) {
+	case "true":
+		if in != "" {
+			return fmt.Errorf("%v configuration error: tty jobs read stdin from attach", c)
+		}
+		instance.TTY, instance.Interactive = true, true
+		captureOutput(instance)
+	case "false", "":
+		instance.TTY = false
+	default:
+		return fmt.Errorf("%v configuration error: invalid value for tty", c)
+	}
+	// Whether the process's output is shown on taskmaster's stdout, labelled
+	// with the instance it came from
+	if console, err := ParseConsole(c); err != nil {
+		return err
+	} else if console {
+		captureOutput(instance)
+		id, _ := strconv.Atoi(c.ID)
+		label := fmt.Sprintf("%s.%d", commandName(instance), instance.InstanceID)
+		attachLines(instance, output.Stdout().Source(label, id),
+			output.Stdout().Source(label, id))
 	}
+	// Lines of output sent to syslog
+	if err := ParseSyslog(c, instance); err != nil {
+		return err
+	}
+	// The most recent lines of output kept in memory
+	if ring, err := ParseRing(c); err != nil {
+		return err
+	} else if ring != nil {
+		captureOutput(instance)
+		instance.Recent = ring
+		attachLines(instance, ring.Source(), ring.Source())
+	}
+	// The limits on the lines of output copied from the process
+	if limiter, err := ParseLimiter(c); err != nil {
+		return err
+	} else {
+		instance.Limiter = limiter
+	}
+	// The window size of the pseudo-terminal
+	if c.TTYSize == "" {
+		instance.TTYSize = pty.DefaultSize
+	} else if size, err := pty.ParseSize(c.TTYSize); err != nil {
+		return fmt.Errorf("%v configuration error: invalid ttySize %s", c, c.TTYSize)
+	} else {
+		instance.TTYSize = size
+	}
+	// Whether the program runs to completion rather than indefinitely
+	instance.Oneshot = strings.ToLower(c.Type) == "oneshot"
 	// Environment variables to set before launching the program
 	instance.EnvVars = strings.Fields(c.EnvVars)
 	// A working directory to set before launching the program
@@ -142,6 +266,67 @@ func ConfigureJob(c CFG.JobConfig, job *JOB.Job, ids map[int]bool) error {
 		message = "%v configuration error: invalid value for atLaunch"
 		return fmt.Errorf(message, c)
 	}
+	// Whether the job is a long running service or runs to completion
+	switch strings.ToLower(c.Type) {
+	case "service", "":
+		job.Oneshot = false
+	case "oneshot":
+		job.Oneshot = true
+	default:
+		return fmt.Errorf(TYPEMSG, c, strings.ToLower(c.Type))
+	}
+	// The jobs which must complete successfully before this job starts
+	job.DependsOn = nil
+	for _, dep := range c.DependsOn {
+		if id, err := strconv.Atoi(dep); err != nil {
+			return fmt.Errorf(DEPENDSONMSG, c, dep)
+		} else {
+			job.DependsOn = append(job.DependsOn, id)
+		}
+	}
+	// Listening sockets bound by taskmaster and passed to each instance
+	if sockets, err := ParseSockets(c); err != nil {
+		return err
+	} else {
+		job.Sockets = sockets
+	}
+	// Whether to wait for a connection on the sockets before starting
+	switch strings.ToLower(c.Lazy) {
+	case "true":
+		job.Lazy = true
+	case "false", "":
+		job.Lazy = false
+	default:
+		return fmt.Errorf("%v configuration error: invalid value for lazy", c)
+	}
+	if job.Lazy && len(job.Sockets) == 0 {
+		return fmt.Errorf("%v configuration error: lazy requires sockets", c)
+	}
+	// Files watched for changes, and what to do to the instances on change
+	if watcher, err := ParseWatch(c); err != nil {
+		return err
+	} else {
+		job.Watcher = watcher
+	}
+	// A cron schedule on which to launch the job, and what to do when the
+	// previous run is still active at the next tick
+	if c.Schedule != "" {
+		sched, err := SCHED.Parse(c.Schedule)
+		if err != nil {
+			return fmt.Errorf("%v configuration error: %s", c, err)
+		}
+		job.Scheduler = &JOB.Scheduler{Schedule: sched}
+		switch strings.ToLower(c.OverlapPolicy) {
+		case "skip", "":
+			job.Scheduler.Overlap = JOB.OVERLAPSKIP
+		case "queue":
+			job.Scheduler.Overlap = JOB.OVERLAPQUEUE
+		case "replace":
+			job.Scheduler.Overlap = JOB.OVERLAPREPLACE
+		default:
+			return fmt.Errorf(OVERLAPMSG, c, strings.ToLower(c.OverlapPolicy))
+		}
+	}
 	return nil
 }
 
@@ -165,12 +350,475 @@ func SetDefaults(configJobs []CFG.JobConfig) ([]*JOB.Job, error) {
 			if err := ConfigureInstance(c, &instance, umask); err != nil {
 				return nil, err
 			}
+			if job.Scheduler != nil {
+				instance.OnExit = append(instance.OnExit, job.RecordRun)
+			}
 		}
 		jobs = append(jobs, &job)
 	}
+	if err := CheckDependencies(jobs); err != nil {
+		return nil, err
+	}
 	return jobs, nil
 }
 
+/*
+ * CheckDependencies verifies that jobs only depend on oneshot jobs which
+ * exist, and that no job depends on itself through its dependencies
+ */
+func CheckDependencies(jobs []*JOB.Job) error {
+	byID := make(map[int]*JOB.Job)
+	for _, job := range jobs {
+		byID[job.ID] = job
+	}
+	for _, job := range jobs {
+		for _, id := range job.DependsOn {
+			if dep, ok := byID[id]; !ok {
+				return fmt.Errorf(DEPENDSONMSG, job, "no job "+strconv.Itoa(id))
+			} else if !dep.Oneshot {
+				return fmt.Errorf(DEPENDSONMSG, job, dep.String()+" is not oneshot")
+			}
+		}
+	}
+	visited := make(map[int]int)
+	var visit func(job *JOB.Job) bool
+	visit = func(job *JOB.Job) bool {
+		if visited[job.ID] == 1 {
+			return false
+		} else if visited[job.ID] == 2 {
+			return true
+		}
+		visited[job.ID] = 1
+		for _, id := range job.DependsOn {
+			if !visit(byID[id]) {
+				return false
+			}
+		}
+		visited[job.ID] = 2
+		return true
+	}
+	for _, job := range jobs {
+		if !visit(job) {
+			return fmt.Errorf(DEPENDSONMSG, job, "circular dependency")
+		}
+	}
+	return nil
+}
+
+/*
+ * ParseStopSequence translates the configured stop steps, accepting either
+ * a duration ("10s") or a number of seconds as the wait of each step
+ */
+func ParseStopSequence(c CFG.JobConfig) ([]INST.StopStep, error) {
+	var sequence []INST.StopStep
+	for n, step := range c.StopSequence {
+		var wait time.Duration
+		sig, err := SIG.Parse(step.Signal)
+		if err != nil {
+			return nil, fmt.Errorf(STOPSEQUENCEMSG, n, c, step.Signal)
+		} else if step.Wait == "" {
+			wait = time.Second
+		} else if val, err := ParseDuration(step.Wait); err != nil {
+			return nil, fmt.Errorf(STOPSEQUENCEMSG, n, c, step.Wait)
+		} else {
+			wait = val
+		}
+		sequence = append(sequence, INST.StopStep{Signal: sig, Wait: wait})
+	}
+	return sequence, nil
+}
+
+/*
+ * ParseDuration accepts either a duration ("10s") or a number of seconds
+ */
+func ParseDuration(val string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(val); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(val)
+}
+
+/*
+ * ParseWatchdog translates the configured resource limits, returning nil if
+ * no limit is set. Cpu usage must stay above maxCpuPercent for
+ * maxCpuDuration, 30s by default, to breach its limit
+ */
+func ParseWatchdog(c CFG.JobConfig) (*INST.Watchdog, error) {
+	var watchdog INST.Watchdog
+	if c.MaxRssMB != "" {
+		if mb, err := strconv.ParseUint(c.MaxRssMB, 10, 64); err != nil || mb == 0 {
+			return nil, fmt.Errorf(WATCHDOGMSG, "maxRssMB", c, c.MaxRssMB)
+		} else {
+			watchdog.MaxRSS = mb << 20
+		}
+	}
+	if c.MaxCpuPercent != "" {
+		if pct, err := strconv.ParseFloat(c.MaxCpuPercent, 64); err != nil || pct <= 0 {
+			return nil, fmt.Errorf(WATCHDOGMSG, "maxCpuPercent", c, c.MaxCpuPercent)
+		} else {
+			watchdog.MaxCPU = pct
+		}
+	}
+	if c.MaxOpenFiles != "" {
+		if files, err := strconv.Atoi(c.MaxOpenFiles); err != nil || files <= 0 {
+			return nil, fmt.Errorf(WATCHDOGMSG, "maxOpenFiles", c, c.MaxOpenFiles)
+		} else {
+			watchdog.MaxFiles = files
+		}
+	}
+	watchdog.CPUDuration = time.Duration(30) * time.Second
+	if c.MaxCpuDuration != "" {
+		if val, err := ParseDuration(c.MaxCpuDuration); err != nil || val < 0 {
+			return nil, fmt.Errorf(WATCHDOGMSG, "maxCpuDuration", c, c.MaxCpuDuration)
+		} else {
+			watchdog.CPUDuration = val
+		}
+	}
+	watchdog.Interval = time.Duration(5) * time.Second
+	if c.WatchdogInterval != "" {
+		if val, err := ParseDuration(c.WatchdogInterval); err != nil || val <= 0 {
+			return nil, fmt.Errorf(WATCHDOGMSG, "watchdogInterval", c, c.WatchdogInterval)
+		} else {
+			watchdog.Interval = val
+		}
+	}
+	if watchdog.MaxRSS == 0 && watchdog.MaxCPU == 0 && watchdog.MaxFiles == 0 {
+		return nil, nil
+	}
+	return &watchdog, nil
+}
+
+/*
+ * ParseSockets translates the configured sockets, defaulting the backlog to
+ * SOMAXCONN and naming unnamed sockets after their position
+ */
+func ParseSockets(c CFG.JobConfig) ([]*JOB.Socket, error) {
+	var sockets []*JOB.Socket
+	for n, cfg := range c.Sockets {
+		var socket JOB.Socket
+		if cfg.Address == "" {
+			return nil, fmt.Errorf(SOCKETMSG, n, c, "address must be specified")
+		}
+		socket.Network, socket.Address = JOB.ParseSocketAddress(cfg.Address)
+		if socket.Name = cfg.Name; socket.Name == "" {
+			socket.Name = "socket" + strconv.Itoa(n)
+		}
+		if cfg.Backlog == "" {
+			socket.Backlog = syscall.SOMAXCONN
+		} else if val, err := strconv.Atoi(cfg.Backlog); err != nil {
+			return nil, fmt.Errorf(SOCKETMSG, n, c, "invalid backlog")
+		} else {
+			socket.Backlog = val
+		}
+		if cfg.Mode != "" {
+			mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf(SOCKETMSG, n, c, "invalid mode")
+			}
+			socket.Mode = os.FileMode(mode)
+		}
+		sockets = append(sockets, &socket)
+	}
+	return sockets, nil
+}
+
+/*
+ * ParseWatch translates the configured watch, defaulting the debounce to
+ * 500ms and the action to restart, returning nil if no paths are watched
+ */
+func ParseWatch(c CFG.JobConfig) (*JOB.Watcher, error) {
+	cfg := c.Watch
+	if len(cfg.Paths) == 0 {
+		if len(cfg.Exclude) != 0 || cfg.Debounce != "" || cfg.Action != "" {
+			return nil, fmt.Errorf(WATCHMSG, c, "paths must be specified")
+		}
+		return nil, nil
+	}
+	watcher := JOB.Watcher{Paths: cfg.Paths, Exclude: cfg.Exclude}
+	for _, pattern := range cfg.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf(WATCHMSG, c, "invalid exclude "+pattern)
+		}
+	}
+	if cfg.Debounce == "" {
+		watcher.Debounce = time.Duration(500) * time.Millisecond
+	} else if debounce, err := time.ParseDuration(cfg.Debounce); err != nil {
+		return nil, fmt.Errorf(WATCHMSG, c, "invalid debounce "+cfg.Debounce)
+	} else {
+		watcher.Debounce = debounce
+	}
+	action := strings.ToLower(cfg.Action)
+	switch {
+	case action == "restart" || action == "":
+		watcher.Action = JOB.WATCHRESTART
+	case strings.HasPrefix(action, "signal:"):
+		name := strings.TrimPrefix(action, "signal:")
+		if sig, err := SIG.Parse(name); err != nil {
+			return nil, fmt.Errorf(WATCHMSG, c, err.Error())
+		} else {
+			watcher.Action = JOB.WATCHSIGNAL
+			watcher.Signal = sig
+		}
+	default:
+		return nil, fmt.Errorf(WATCHMSG, c, "action must be restart or signal:SIG")
+	}
+	return &watcher, nil
+}
+
+/*
+ * LogPath returns the file an instance's output is written to. Instances of
+ * a job running more than one write to their own file, numbered before its
+ * extension, e.g. out.log becomes out.0.log, out.1.log...
+ */
+func LogPath(c CFG.JobConfig, instance int, path string) string {
+	if pool, err := strconv.Atoi(c.Instances); path == "" || err != nil || pool <= 1 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), instance, ext)
+}
+
+/*
+ * ParseRotation translates the rotation of the job's log files, maxBytes
+ * accepting a number of bytes or a size in KB, MB or GB
+ */
+func ParseRotation(c CFG.JobConfig) (output.Rotation, error) {
+	var rotation output.Rotation
+	cfg := c.LogRotation
+	if cfg.MaxBytes != "" {
+		if val, err := ParseBytes(cfg.MaxBytes); err != nil || val <= 0 {
+			return rotation, fmt.Errorf(ROTATIONMSG, "maxBytes", c, cfg.MaxBytes)
+		} else {
+			rotation.MaxBytes = val
+		}
+	}
+	rotation.Backups = 10
+	if cfg.Backups != "" {
+		if val, err := strconv.Atoi(cfg.Backups); err != nil || val < 0 {
+			return rotation, fmt.Errorf(ROTATIONMSG, "backups", c, cfg.Backups)
+		} else {
+			rotation.Backups = val
+		}
+	}
+	switch strings.ToLower(cfg.Compress) {
+	case "true":
+		rotation.Compress = true
+	case "false", "":
+		rotation.Compress = false
+	default:
+		return rotation, fmt.Errorf(ROTATIONMSG, "compress", c, cfg.Compress)
+	}
+	return rotation, nil
+}
+
+/*
+ * ParseFraming translates how lines written to the job's log files are
+ * framed: outputTimestamps of rfc3339, unix or none, & an outputPrefix
+ * template using .Job, .Instance, .Pid & .Stream
+ */
+func ParseFraming(c CFG.JobConfig) (output.Framing, error) {
+	if _, err := output.NewFraming(c.OutputTimestamps, ""); err != nil {
+		return output.Framing{}, fmt.Errorf(INVALIDMSG, "outputTimestamps", c, c.OutputTimestamps)
+	}
+	framing, err := output.NewFraming(c.OutputTimestamps, c.OutputPrefix)
+	if err != nil {
+		return framing, fmt.Errorf(INVALIDMSG, "outputPrefix", c, err)
+	}
+	return framing, nil
+}
+
+/*
+ * captureOutput makes taskmaster capture the process's stdout & stderr
+ * through pipes, copying them to broadcasters
+ */
+func captureOutput(instance *INST.Instance) {
+	if instance.Stdout == nil {
+		instance.Stdout = output.NewBroadcaster(nil)
+		instance.Stderr = output.NewBroadcaster(nil)
+	}
+}
+
+/*
+ * attachLines attaches line writers to the instance's stdout & stderr, to be
+ * flushed once the process exits
+ */
+func attachLines(instance *INST.Instance, stdout, stderr *output.LineWriter) {
+	for n, lines := range []*output.LineWriter{stdout, stderr} {
+		if lines == nil {
+			continue
+		} else if n == 0 {
+			instance.Stdout.Attach(lines)
+		} else {
+			instance.Stderr.Attach(lines)
+		}
+		instance.Lines = append(instance.Lines, lines)
+	}
+}
+
+/*
+ * ParseRing creates the buffer of the job's most recent output, holding
+ * bufferLines lines, disabled by default as it pipes the output through
+ * taskmaster, of up to bufferBytes bytes in total, 64KB by default
+ */
+func ParseRing(c CFG.JobConfig) (*output.Ring, error) {
+	lines, size := 0, int64(64<<10)
+	if c.BufferLines != "" {
+		if val, err := strconv.Atoi(c.BufferLines); err != nil || val < 0 {
+			return nil, fmt.Errorf(INVALIDMSG, "bufferLines", c, c.BufferLines)
+		} else {
+			lines = val
+		}
+	}
+	if c.BufferBytes != "" {
+		if val, err := ParseBytes(c.BufferBytes); err != nil || val <= 0 {
+			return nil, fmt.Errorf(INVALIDMSG, "bufferBytes", c, c.BufferBytes)
+		} else {
+			size = val
+		}
+	}
+	if lines == 0 {
+		return nil, nil
+	}
+	return output.NewRing(lines, int(size)), nil
+}
+
+/*
+ * ParseLimiter creates the limits on the job's output: maxLinesPerSecond
+ * lines a second after a burst of maxLinesBurst, by default the rate, &
+ * lines of up to maxLineLength bytes, a number or a size in KB or MB, 0
+ * disabling a limit. Nil if the output is not limited
+ */
+func ParseLimiter(c CFG.JobConfig) (*output.Limiter, error) {
+	var rate, burst, length int
+	if c.MaxLinesPerSecond != "" {
+		if val, err := strconv.Atoi(c.MaxLinesPerSecond); err != nil || val < 0 {
+			return nil, fmt.Errorf(INVALIDMSG, "maxLinesPerSecond", c, c.MaxLinesPerSecond)
+		} else {
+			rate = val
+		}
+	}
+	if c.MaxLinesBurst != "" {
+		if val, err := strconv.Atoi(c.MaxLinesBurst); err != nil || val < 0 {
+			return nil, fmt.Errorf(INVALIDMSG, "maxLinesBurst", c, c.MaxLinesBurst)
+		} else {
+			burst = val
+		}
+	}
+	if c.MaxLineLength != "" {
+		if val, err := ParseBytes(c.MaxLineLength); err != nil || val < 0 {
+			return nil, fmt.Errorf(INVALIDMSG, "maxLineLength", c, c.MaxLineLength)
+		} else {
+			length = int(val)
+		}
+	}
+	if rate == 0 && length == 0 {
+		return nil, nil
+	}
+	return output.NewLimiter(rate, burst, length), nil
+}
+
+/*
+ * ParseSyslog sends each line of the job's stdout & stderr to syslog when
+ * stdoutSyslog or stderrSyslog is true or names the severity to send them
+ * with, info for stdout & err for stderr by default. The command's name is
+ * the app-name & the stream the message id
+ */
+func ParseSyslog(c CFG.JobConfig, instance *INST.Instance) error {
+	streams := []struct {
+		name, val string
+		severity  syslog.Priority
+	}{
+		{"stdout", c.StdoutSyslog, syslog.LOG_INFO},
+		{"stderr", c.StderrSyslog, syslog.LOG_ERR},
+	}
+	writers := make([]*output.LineWriter, len(streams))
+	app := ""
+	for n, stream := range streams {
+		severity := stream.severity
+		switch strings.ToLower(stream.val) {
+		case "", "false":
+			continue
+		case "true":
+		default:
+			var err error
+			if severity, err = ParseSeverity(stream.val); err != nil {
+				return fmt.Errorf("%v configuration error: %s: %v", c,
+					stream.name+"Syslog", err)
+			}
+		}
+		if app == "" {
+			app = commandName(instance)
+		}
+		msgid := stream.name
+		writers[n] = output.NewLineWriter(func(line []byte) {
+			DefaultSyslog().Send(severity, app, "-", msgid, string(line))
+		})
+	}
+	if writers[0] != nil || writers[1] != nil {
+		captureOutput(instance)
+		attachLines(instance, writers[0], writers[1])
+	}
+	return nil
+}
+
+/*
+ * commandName returns the name of the program the instance runs, used to
+ * label its output
+ */
+func commandName(instance *INST.Instance) string {
+	if len(instance.Args) == 0 {
+		return "-"
+	}
+	return filepath.Base(instance.Args[0])
+}
+
+/*
+ * ParseConsole returns whether the job's output is shown on the console,
+ * defaulting to ConsoleOutput
+ */
+func ParseConsole(c CFG.JobConfig) (bool, error) {
+	switch strings.ToLower(c.Console) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return ConsoleOutput, nil
+	}
+	return false, fmt.Errorf("%v configuration error: invalid value for console", c)
+}
+
+/*
+ * ParseBytes translates a number of bytes, optionally suffixed with KB, MB or
+ * GB
+ */
+func ParseBytes(val string) (int64, error) {
+	units := []struct {
+		suffix string
+		shift  uint
+	}{{"KB", 10}, {"MB", 20}, {"GB", 30}, {"B", 0}}
+	upper := strings.ToUpper(strings.TrimSpace(val))
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			n, err := strconv.ParseInt(strings.TrimSuffix(upper, unit.suffix), 10, 64)
+			return n << unit.shift, err
+		}
+	}
+	return strconv.ParseInt(upper, 10, 64)
+}
+
+/*
+ * CheckRedir verifies the file output is redirected to can be written,
+ * creating it if needed
+ */
+func CheckRedir(val string) error {
+	f, err := OpenRedir(val, stdoutFlags)
+	if f != nil {
+		f.Close()
+	}
+	return err
+}
+
 /*
  * OpenRedir opens the given file for use in Jobess's redirections
  */
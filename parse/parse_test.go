@@ -74,6 +74,42 @@ func TestConfigParseIntSetsValue(t *testing.T) {
 	Buf.Reset()
 }
 
+func TestConfigConfigureInstanceErrorsOnEmptyCommand(t *testing.T) {
+	for _, command := range []string{"", "   "} {
+		c := CFG.JobConfig{ID: "1", Command: command, StdoutSyslog: "true"}
+		if err := ConfigureInstance(c, &INST.Instance{}, 0); err == nil {
+			t.Errorf("ConfigureInstance should error on command %q", command)
+		}
+	}
+	if err := ParseSyslog(CFG.JobConfig{}, &INST.Instance{}); err != nil {
+		t.Errorf("ParseSyslog should ignore the command when disabled: %s", err)
+	}
+	Buf.Reset()
+}
+
+func TestConfigLogPathNumbersFilesPerInstance(t *testing.T) {
+	single := CFG.JobConfig{Instances: "1"}
+	pool := CFG.JobConfig{Instances: "2"}
+	for _, test := range []struct {
+		c        CFG.JobConfig
+		instance int
+		path     string
+		expected string
+	}{
+		{single, 0, "logs/out.log", "logs/out.log"},
+		{pool, 0, "logs/out.log", "logs/out.0.log"},
+		{pool, 1, "logs/out.log", "logs/out.1.log"},
+		{pool, 1, "logs/out", "logs/out.1"},
+		{pool, 1, "", ""},
+	} {
+		if path := LogPath(test.c, test.instance, test.path); path != test.expected {
+			t.Errorf("LogPath of %q for instance %d should be %q, actually %q",
+				test.path, test.instance, test.expected, path)
+		}
+	}
+	Buf.Reset()
+}
+
 func TestConfigConfigureInstance(t *testing.T) {
 	var i INST.Instance
 	c := CFG.JobConfig{
@@ -163,7 +199,7 @@ func TestConfigConfigureJob(t *testing.T) {
 		t.Errorf("ConfigureJob doesnt correctly set Instances")
 	} else if j.Pool != 1 {
 		t.Errorf("ConfigureJob doesnt correctly set pool")
-	} else if *j.Cfg != c {
+	} else if !j.Cfg.Same(&c) {
 		t.Errorf("ConfigureJob doesnt correctly set cfg")
 	} else if j.AtLaunch != true {
 		t.Errorf("ConfigureJob doesnt correctly set AtLaunch")
@@ -0,0 +1,178 @@
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Root is the mount point of the proc filesystem
+ */
+var Root = "/proc"
+
+/*
+ * CgroupRoot is the mount point of the cgroup filesystem
+ */
+var CgroupRoot = "/sys/fs/cgroup"
+
+/*
+ * ClockTicks is the number of clock ticks per second that Utime and Stime
+ * are measured in, USER_HZ is fixed at 100 on linux
+ */
+const ClockTicks = 100
+
+/*
+ * Stat holds the fields of /proc/<pid>/stat used by taskmaster,
+ * see `man 5 proc`
+ */
+type Stat struct {
+	Pid       int
+	Comm      string
+	State     byte
+	PPid      int
+	Pgrp      int
+	Session   int
+	Utime     uint64
+	Stime     uint64
+	StartTime uint64
+}
+
+/*
+ * ReadStat parses /proc/<pid>/stat for the given process
+ */
+func ReadStat(pid int) (*Stat, error) {
+	path := fmt.Sprintf("%s/%d/stat", Root, pid)
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStat(string(buf))
+}
+
+/*
+ * ParseStat parses the contents of a stat file. The command name is wrapped
+ * in parentheses and may itself contain spaces or parentheses, so the fields
+ * are split after the last closing parenthesis
+ */
+func ParseStat(data string) (*Stat, error) {
+	open := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("proc: malformed stat: %q", data)
+	}
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("proc: malformed stat: %q", data)
+	}
+	var stat Stat
+	var err error
+	if stat.Pid, err = strconv.Atoi(strings.TrimSpace(data[:open])); err != nil {
+		return nil, err
+	}
+	stat.Comm = data[open+1 : end]
+	stat.State = fields[0][0]
+	// fields are offset by 3 from their numbering in `man 5 proc`
+	ints := []*int{&stat.PPid, &stat.Pgrp, &stat.Session}
+	for n, dst := range ints {
+		if *dst, err = strconv.Atoi(fields[n+1]); err != nil {
+			return nil, err
+		}
+	}
+	uints := map[int]*uint64{11: &stat.Utime, 12: &stat.Stime, 19: &stat.StartTime}
+	for n, dst := range uints {
+		if *dst, err = strconv.ParseUint(fields[n], 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return &stat, nil
+}
+
+/*
+ * Pids lists the processes currently visible in /proc
+ */
+func Pids() ([]int, error) {
+	entries, err := ioutil.ReadDir(Root)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+/*
+ * ReadRSS returns the resident set size of the process in bytes, read from
+ * /proc/<pid>/statm
+ */
+func ReadRSS(pid int) (uint64, error) {
+	path := fmt.Sprintf("%s/%d/statm", Root, pid)
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return ParseStatm(string(buf))
+}
+
+/*
+ * ParseStatm parses the contents of a statm file, returning the resident
+ * set size in bytes
+ */
+func ParseStatm(data string) (uint64, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("proc: malformed statm: %q", data)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+/*
+ * OpenFiles counts the file descriptors the process has open
+ */
+func OpenFiles(pid int) (int, error) {
+	entries, err := ioutil.ReadDir(fmt.Sprintf("%s/%d/fd", Root, pid))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+/*
+ * ReadCgroups returns the cgroups of the process, see ParseCgroups
+ */
+func ReadCgroups(pid int) (map[string]string, error) {
+	buf, err := ioutil.ReadFile(fmt.Sprintf("%s/%d/cgroup", Root, pid))
+	if err != nil {
+		return nil, err
+	}
+	return ParseCgroups(string(buf)), nil
+}
+
+/*
+ * ParseCgroups parses the contents of a cgroup file into the path of the
+ * process's cgroup keyed by controller, the cgroup v2 unified hierarchy is
+ * keyed by the empty string
+ */
+func ParseCgroups(data string) map[string]string {
+	cgroups := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			cgroups[controller] = fields[2]
+		}
+	}
+	return cgroups
+}
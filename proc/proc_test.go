@@ -0,0 +1,70 @@
+package proc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestProcParseStat(t *testing.T) {
+	data := "42 (a (weird) name) Z 1 40 40 0 -1 4194304 78 0 0 0 " +
+		"7 3 0 0 20 0 1 0 67969 2703360 286"
+	if stat, err := ParseStat(data); err != nil {
+		t.Error("ParseStat should parse a valid stat line:", err)
+	} else if stat.Pid != 42 || stat.Comm != "a (weird) name" {
+		t.Errorf("ParseStat doesnt correctly set pid & comm: %+v", stat)
+	} else if stat.State != 'Z' || stat.PPid != 1 || stat.Pgrp != 40 {
+		t.Errorf("ParseStat doesnt correctly set state, ppid & pgrp: %+v", stat)
+	} else if stat.Utime != 7 || stat.Stime != 3 || stat.StartTime != 67969 {
+		t.Errorf("ParseStat doesnt correctly set times: %+v", stat)
+	}
+}
+
+func TestProcParseStatErrorsOnMalformed(t *testing.T) {
+	if _, err := ParseStat("42 foo"); err == nil {
+		t.Errorf("ParseStat should return an error on malformed stat")
+	}
+}
+
+func TestProcReadStatSelf(t *testing.T) {
+	if stat, err := ReadStat(os.Getpid()); err != nil {
+		t.Skip("proc filesystem unavailable:", err)
+	} else if stat.PPid != os.Getppid() {
+		t.Errorf("ReadStat doesnt correctly set ppid: %+v", stat)
+	}
+}
+
+func TestProcParseStatm(t *testing.T) {
+	if rss, err := ParseStatm("2566 286 245 3 0 110 0\n"); err != nil {
+		t.Error("ParseStatm should parse a valid statm line:", err)
+	} else if rss != 286*uint64(os.Getpagesize()) {
+		t.Errorf("ParseStatm doesnt correctly compute rss: %d", rss)
+	}
+	if _, err := ParseStatm("2566"); err == nil {
+		t.Errorf("ParseStatm should return an error on malformed statm")
+	}
+}
+
+func TestProcOpenFilesSelf(t *testing.T) {
+	f, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Skip("unable to open test binary:", err)
+	}
+	before, err := OpenFiles(os.Getpid())
+	if err != nil {
+		t.Skip("proc filesystem unavailable:", err)
+	}
+	f.Close()
+	if after, _ := OpenFiles(os.Getpid()); after != before-1 {
+		t.Errorf("OpenFiles doesnt count closed fds: %d then %d", before, after)
+	}
+}
+
+func TestProcParseCgroups(t *testing.T) {
+	data := "4:memory:/jobs/worker\n2:cpu,cpuacct:/\n0::/jobs/worker\n"
+	cgroups := ParseCgroups(data)
+	if cgroups["memory"] != "/jobs/worker" || cgroups[""] != "/jobs/worker" {
+		t.Errorf("ParseCgroups doesnt correctly set paths: %v", cgroups)
+	} else if cgroups["cpu"] != "/" || cgroups["cpuacct"] != "/" {
+		t.Errorf("ParseCgroups doesnt split controllers: %v", cgroups)
+	}
+}
@@ -0,0 +1,46 @@
+package pty
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Size is the window size of a terminal in characters
+ */
+type Size struct {
+	Rows uint16
+	Cols uint16
+}
+
+/*
+ * DefaultSize is the window size of a terminal when none is configured
+ */
+var DefaultSize = Size{Rows: 24, Cols: 80}
+
+/*
+ * ParseSize parses a window size written as <cols>x<rows>, e.g. 80x24
+ */
+func ParseSize(val string) (Size, error) {
+	parts := strings.Split(strings.ToLower(val), "x")
+	if len(parts) != 2 {
+		return Size{}, fmt.Errorf("invalid window size %q, expected <cols>x<rows>", val)
+	}
+	cols, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil || cols == 0 {
+		return Size{}, fmt.Errorf("invalid window size %q, expected <cols>x<rows>", val)
+	}
+	rows, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil || rows == 0 {
+		return Size{}, fmt.Errorf("invalid window size %q, expected <cols>x<rows>", val)
+	}
+	return Size{Rows: uint16(rows), Cols: uint16(cols)}, nil
+}
+
+/*
+ * String is the printed representation of the size, as parsed by ParseSize
+ */
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Cols, s.Rows)
+}
@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package pty
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+/*
+ * Open allocates a pseudo-terminal pair from /dev/ptmx, returning the master
+ * side and the slave side, see `man 7 pty`
+ */
+func Open() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unlock int32
+	var n uint32
+	if err := ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, err
+	} else if err := ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+/*
+ * SetSize sets the window size of the terminal
+ */
+func SetSize(tty *os.File, size Size) error {
+	ws := winsize{Rows: size.Rows, Cols: size.Cols}
+	return ioctl(tty, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+/*
+ * GetSize returns the window size of the terminal
+ */
+func GetSize(tty *os.File) (Size, error) {
+	var ws winsize
+	err := ioctl(tty, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	return Size{Rows: ws.Rows, Cols: ws.Cols}, err
+}
+
+/*
+ * IsTerminal reports whether the file is a terminal
+ */
+func IsTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	return ioctl(f, syscall.TCGETS, uintptr(unsafe.Pointer(&termios))) == nil
+}
+
+// winsize from <asm-generic/termios.h>
+type winsize struct {
+	Rows   uint16
+	Cols   uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+func ioctl(f *os.File, request, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+// +build !linux
+
+package pty
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+ * Open is only supported on linux
+ */
+func Open() (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("PTY Error: pseudo-terminals require linux")
+}
+
+/*
+ * SetSize is only supported on linux
+ */
+func SetSize(tty *os.File, size Size) error {
+	return fmt.Errorf("PTY Error: pseudo-terminals require linux")
+}
+
+/*
+ * GetSize is only supported on linux
+ */
+func GetSize(tty *os.File) (Size, error) {
+	return Size{}, fmt.Errorf("PTY Error: pseudo-terminals require linux")
+}
+
+/*
+ * IsTerminal is only supported on linux, reporting every file as not being
+ * a terminal
+ */
+func IsTerminal(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,53 @@
+package pty
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPtyParseSize(t *testing.T) {
+	if size, err := ParseSize("132x43"); err != nil {
+		t.Error("ParseSize should parse a valid size:", err)
+	} else if size.Cols != 132 || size.Rows != 43 || size.String() != "132x43" {
+		t.Errorf("ParseSize doesnt correctly set cols & rows: %+v", size)
+	}
+	for _, val := range []string{"", "80", "0x24", "80x", "ax24", "80x24x1"} {
+		if _, err := ParseSize(val); err == nil {
+			t.Errorf("ParseSize should return an error for %q", val)
+		}
+	}
+}
+
+func TestPtyOpenSetsSize(t *testing.T) {
+	master, slave, err := Open()
+	if err != nil {
+		t.Skip("pseudo-terminals unavailable:", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+	if err := SetSize(master, Size{Rows: 50, Cols: 100}); err != nil {
+		t.Error("SetSize should set the window size:", err)
+	} else if size, err := GetSize(slave); err != nil || size.String() != "100x50" {
+		t.Errorf("GetSize should return the size set: %v %v", size, err)
+	}
+}
+
+func TestPtyIsTerminal(t *testing.T) {
+	master, slave, err := Open()
+	if err != nil {
+		t.Skip("pseudo-terminals unavailable:", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if !IsTerminal(slave) {
+		t.Error("IsTerminal should report the slave side as a terminal")
+	} else if IsTerminal(w) {
+		t.Error("IsTerminal should not report a pipe as a terminal")
+	}
+}
@@ -0,0 +1,185 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Schedule is a parsed cron expression, each field stored as a bitset of
+ * the values it matches
+ */
+type Schedule struct {
+	Spec    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+/*
+ * Parse reads a standard five field cron expression
+ * "minute hour day-of-month month day-of-week", see `man 5 crontab`
+ */
+func Parse(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if macro, ok := macros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Error: schedule %q must have 5 fields", spec)
+	}
+	s := &Schedule{Spec: spec}
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	} else if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	} else if s.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, err
+	} else if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	} else if s.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, err
+	}
+	// sunday may be written as either 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+/*
+ * parseField parses a comma separated list of values, ranges and steps
+ */
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if n := strings.Index(part, "/"); n != -1 {
+			val, err := strconv.Atoi(part[n+1:])
+			if err != nil || val <= 0 {
+				return 0, fmt.Errorf("Error: invalid schedule step %q", part)
+			}
+			part, step = part[:n], val
+		}
+		low, high := b.min, b.max
+		if part != "*" {
+			var err error
+			bounds := strings.SplitN(part, "-", 2)
+			if low, err = parseValue(bounds[0], b); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseValue(bounds[1], b); err != nil {
+					return 0, err
+				}
+			} else if step != 1 {
+				high = b.max
+			}
+		}
+		if low > high {
+			return 0, fmt.Errorf("Error: invalid schedule range %q", part)
+		}
+		for val := low; val <= high; val += step {
+			bits |= 1 << uint(val)
+		}
+	}
+	return bits, nil
+}
+
+/*
+ * parseValue reads a single number or name within the field's bounds
+ */
+func parseValue(str string, b bounds) (int, error) {
+	if val, ok := b.names[strings.ToLower(str)]; ok {
+		return val, nil
+	}
+	val, err := strconv.Atoi(str)
+	if err != nil || val < b.min || val > b.max {
+		return 0, fmt.Errorf("Error: invalid schedule value %q", str)
+	}
+	return val, nil
+}
+
+/*
+ * Next returns the first time matching the schedule strictly after t, or
+ * the zero time if none is found within five years
+ */
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		} else if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		} else if s.hour&(1<<uint(t.Hour())) == 0 {
+			// stepped in wall clock time, as Truncate rounds absolute time
+			// & so misses the hour in zones offset by half an hour
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		} else if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+		} else {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+/*
+ * matchDay applies cron's rule that when both the day of month and day of
+ * week are restricted, a day matching either is scheduled
+ */
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+/*
+ * String is the printed representation of the struct
+ */
+func (s *Schedule) String() string {
+	return s.Spec
+}
@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, spec string) *Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse should accept %q: %s", spec, err)
+	}
+	return s
+}
+
+func TestScheduleNext(t *testing.T) {
+	start := time.Date(2020, time.March, 14, 15, 9, 26, 0, time.UTC)
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2020, time.March, 14, 15, 10, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2020, time.March, 15, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, time.March, 14, 15, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, time.March, 14, 16, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2020, time.March, 16, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,20 * 1", time.Date(2020, time.March, 16, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if next := mustParse(t, c.spec).Next(start); !next.Equal(c.next) {
+			t.Errorf("Next for %q should be %v, actually %v", c.spec, c.next, next)
+		}
+	}
+}
+
+func TestScheduleNextHalfHourZone(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*3600+30*60)
+	start := time.Date(2020, time.March, 14, 9, 45, 0, 0, kolkata)
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"0 11 * * *", time.Date(2020, time.March, 14, 11, 0, 0, 0, kolkata)},
+		{"@hourly", time.Date(2020, time.March, 14, 10, 0, 0, 0, kolkata)},
+		{"30 9 * * *", time.Date(2020, time.March, 15, 9, 30, 0, 0, kolkata)},
+	}
+	for _, c := range cases {
+		if next := mustParse(t, c.spec).Next(start); !next.Equal(c.next) {
+			t.Errorf("Next for %q should be %v, actually %v", c.spec, c.next, next)
+		}
+	}
+}
+
+func TestScheduleParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "5-1 * * * *", "*/0 * * * *", "foo * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse should reject %q", spec)
+		}
+	}
+}
@@ -1,119 +1,100 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
-const (
-	// SIGEXISTS If sig is 0, then no signal is sent, but error checking is
-	// still performed; this can be used to check for the existence of a
-	// process ID or process group ID.
-	SIGEXISTS int = iota
-	// SIGHUP terminal line hangup
-	SIGHUP
-	// SIGINT interrupt program
-	SIGINT
-	// SIGQUIT quit program
-	SIGQUIT
-	// SIGILL illegal instruction
-	SIGILL
-	// SIGTRAP trace trap
-	SIGTRAP
-	// SIGABRT abort program (formerly SIGIOT)
-	SIGABRT
-	// SIGEMT emulate instruction executed
-	SIGEMT
-	// SIGFPE floating-point exception
-	SIGFPE
-	// SIGKILL kill program
-	SIGKILL
-	// SIGBUS bus error
-	SIGBUS
-	// SIGSEGV segmentation violation
-	SIGSEGV
-	// SIGSYS non-existent system call invoked
-	SIGSYS
-	// SIGPIPE write on a pipe with no reader
-	SIGPIPE
-	// SIGALRM real-time timer expired
-	SIGALRM
-	// SIGTERM software termination signal
-	SIGTERM
-	// SIGURG urgent condition present on socket
-	SIGURG
-	// SIGSTOP stop (cannot be caught or ignored)
-	SIGSTOP
-	// SIGTSTP stop signal generated from keyboard
-	SIGTSTP
-	// SIGCONT continue after stop
-	SIGCONT
-	// SIGCHLD child Status has changed
-	SIGCHLD
-	// SIGTTIN background read attempted from control terminal
-	SIGTTIN
-	// SIGTTOU background write attempted to control terminal
-	SIGTTOU
-	// SIGIO I/O is possible on a descriptor (see fcntl(2))
-	SIGIO
-	// SIGXCPU cpu time limit exceeded (see setrlimit(2))
-	SIGXCPU
-	// SIGXFSZ file size limit exceeded (see setrlimit(2))
-	SIGXFSZ
-	// SIGVTALRM virtual time alarm (see setitimer(2))
-	SIGVTALRM
-	// SIGPROF profiling timer alarm (see setitimer(2))
-	SIGPROF
-	// SIGWINCH Window size change
-	SIGWINCH
-	// SIGINFO Status request from keyboard
-	SIGINFO
-	// SIGUSR1 User defined signal 1
-	SIGUSR1
-	// SIGUSR2 User defined signal 2
-	SIGUSR2
-)
+// SIGEXISTS If sig is 0, then no signal is sent, but error checking is
+// still performed; this can be used to check for the existence of a
+// process ID or process group ID.
+const SIGEXISTS = syscall.Signal(0)
+
+// Signals maps signal names to the platform's syscall signals
+var Signals = map[string]syscall.Signal{"SIGEXISTS": SIGEXISTS}
+
+// names maps the platform's signals to their names, excluding aliases
+var names = map[syscall.Signal]string{}
+
+func init() {
+	for name, sig := range platformSignals {
+		Signals[name] = sig
+		if !aliases[name] {
+			names[sig] = name
+		}
+	}
+}
+
+// Parse translates a signal name, with or without its SIG prefix, a real
+// time signal relative to SIGRTMIN or SIGRTMAX, e.g. SIGRTMIN+3, or a signal
+// number into the platform's signal
+func Parse(name string) (syscall.Signal, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	if n, err := strconv.Atoi(upper); err == nil {
+		if n < 0 || n > MAXSIGNAL {
+			return 0, fmt.Errorf("unknown signal %s", name)
+		}
+		return syscall.Signal(n), nil
+	}
+	if !strings.HasPrefix(upper, "SIG") {
+		upper = "SIG" + upper
+	}
+	if sig, ok := Signals[upper]; ok {
+		return sig, nil
+	} else if sig, ok := parseRealtime(upper); ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %s", upper)
+}
+
+// Name returns the name of the signal, as accepted by Parse
+func Name(sig syscall.Signal) string {
+	if name, ok := names[sig]; ok {
+		return name
+	} else if SIGRTMIN != 0 && int(sig) == SIGRTMIN {
+		return "SIGRTMIN"
+	} else if SIGRTMIN != 0 && int(sig) > SIGRTMIN && int(sig) <= SIGRTMAX {
+		return fmt.Sprintf("SIGRTMIN+%d", int(sig)-SIGRTMIN)
+	}
+	return fmt.Sprintf("%d", int(sig))
+}
 
-// Signals maps signal names to syscall signals
-var Signals = map[string]syscall.Signal{
-	"SIGEXISTS": syscall.Signal(SIGEXISTS),
-	"SIGHUP":    syscall.Signal(SIGHUP),
-	"SIGINT":    syscall.Signal(SIGINT),
-	"SIGQUIT":   syscall.Signal(SIGQUIT),
-	"SIGILL":    syscall.Signal(SIGILL),
-	"SIGTRAP":   syscall.Signal(SIGTRAP),
-	"SIGABRT":   syscall.Signal(SIGABRT),
-	"SIGEMT":    syscall.Signal(SIGEMT),
-	"SIGFPE":    syscall.Signal(SIGFPE),
-	"SIGKILL":   syscall.Signal(SIGKILL),
-	"SIGBUS":    syscall.Signal(SIGBUS),
-	"SIGSEGV":   syscall.Signal(SIGSEGV),
-	"SIGSYS":    syscall.Signal(SIGSYS),
-	"SIGPIPE":   syscall.Signal(SIGPIPE),
-	"SIGALRM":   syscall.Signal(SIGALRM),
-	"SIGTERM":   syscall.Signal(SIGTERM),
-	"SIGURG":    syscall.Signal(SIGURG),
-	"SIGSTOP":   syscall.Signal(SIGSTOP),
-	"SIGTSTP":   syscall.Signal(SIGTSTP),
-	"SIGCONT":   syscall.Signal(SIGCONT),
-	"SIGCHLD":   syscall.Signal(SIGCHLD),
-	"SIGTTIN":   syscall.Signal(SIGTTIN),
-	"SIGTTOU":   syscall.Signal(SIGTTOU),
-	"SIGIO":     syscall.Signal(SIGIO),
-	"SIGXCPU":   syscall.Signal(SIGXCPU),
-	"SIGXFSZ":   syscall.Signal(SIGXFSZ),
-	"SIGVTALRM": syscall.Signal(SIGVTALRM),
-	"SIGPROF":   syscall.Signal(SIGPROF),
-	"SIGWINCH":  syscall.Signal(SIGWINCH),
-	"SIGINFO":   syscall.Signal(SIGINFO),
-	"SIGUSR1":   syscall.Signal(SIGUSR1),
-	"SIGUSR2":   syscall.Signal(SIGUSR2),
+// parseRealtime translates SIGRTMIN[+N] & SIGRTMAX[-N] within the range of
+// real time signals, which only some platforms support
+func parseRealtime(name string) (syscall.Signal, bool) {
+	var base, sign int
+	if strings.HasPrefix(name, "SIGRTMIN") {
+		base, sign, name = SIGRTMIN, 1, strings.TrimPrefix(name, "SIGRTMIN")
+	} else if strings.HasPrefix(name, "SIGRTMAX") {
+		base, sign, name = SIGRTMAX, -1, strings.TrimPrefix(name, "SIGRTMAX")
+	} else {
+		return 0, false
+	}
+	if SIGRTMIN == 0 {
+		return 0, false
+	} else if name == "" {
+		return syscall.Signal(base), true
+	} else if sign == 1 && !strings.HasPrefix(name, "+") ||
+		sign == -1 && !strings.HasPrefix(name, "-") {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(name[1:])
+	if sig := base + sign*offset; err != nil || offset < 0 ||
+		sig < SIGRTMIN || sig > SIGRTMAX {
+		return 0, false
+	} else {
+		return syscall.Signal(sig), true
+	}
 }
 
-//InitSignals registers the channel used to manage signals sent to TaskMaster
+// InitSignals registers the channel used to manage signals sent to TaskMaster
 func InitSignals() chan os.Signal {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM,
+		syscall.SIGUSR1, syscall.SIGUSR2)
 	return c
 }
@@ -0,0 +1,52 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package utils
+
+import "syscall"
+
+const (
+	// SIGRTMIN real time signals are not supported
+	SIGRTMIN = 0
+	// SIGRTMAX real time signals are not supported
+	SIGRTMAX = 0
+	// MAXSIGNAL largest signal number
+	MAXSIGNAL = 31
+)
+
+var aliases = map[string]bool{"SIGIOT": true}
+
+var platformSignals = map[string]syscall.Signal{
+	"SIGHUP":    syscall.SIGHUP,
+	"SIGINT":    syscall.SIGINT,
+	"SIGQUIT":   syscall.SIGQUIT,
+	"SIGILL":    syscall.SIGILL,
+	"SIGTRAP":   syscall.SIGTRAP,
+	"SIGABRT":   syscall.SIGABRT,
+	"SIGIOT":    syscall.SIGIOT,
+	"SIGEMT":    syscall.SIGEMT,
+	"SIGFPE":    syscall.SIGFPE,
+	"SIGKILL":   syscall.SIGKILL,
+	"SIGBUS":    syscall.SIGBUS,
+	"SIGSEGV":   syscall.SIGSEGV,
+	"SIGSYS":    syscall.SIGSYS,
+	"SIGPIPE":   syscall.SIGPIPE,
+	"SIGALRM":   syscall.SIGALRM,
+	"SIGTERM":   syscall.SIGTERM,
+	"SIGURG":    syscall.SIGURG,
+	"SIGSTOP":   syscall.SIGSTOP,
+	"SIGTSTP":   syscall.SIGTSTP,
+	"SIGCONT":   syscall.SIGCONT,
+	"SIGCHLD":   syscall.SIGCHLD,
+	"SIGTTIN":   syscall.SIGTTIN,
+	"SIGTTOU":   syscall.SIGTTOU,
+	"SIGIO":     syscall.SIGIO,
+	"SIGXCPU":   syscall.SIGXCPU,
+	"SIGXFSZ":   syscall.SIGXFSZ,
+	"SIGVTALRM": syscall.SIGVTALRM,
+	"SIGPROF":   syscall.SIGPROF,
+	"SIGWINCH":  syscall.SIGWINCH,
+	"SIGINFO":   syscall.SIGINFO,
+	"SIGUSR1":   syscall.SIGUSR1,
+	"SIGUSR2":   syscall.SIGUSR2,
+}
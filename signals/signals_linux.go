@@ -0,0 +1,55 @@
+//go:build linux
+// +build linux
+
+package utils
+
+import "syscall"
+
+const (
+	// SIGRTMIN first real time signal available to programs, as glibc
+	// reserves the first two for its threading implementation
+	SIGRTMIN = 34
+	// SIGRTMAX last real time signal
+	SIGRTMAX = 64
+	// MAXSIGNAL largest signal number
+	MAXSIGNAL = SIGRTMAX
+)
+
+var aliases = map[string]bool{"SIGIOT": true, "SIGCLD": true, "SIGPOLL": true}
+
+var platformSignals = map[string]syscall.Signal{
+	"SIGHUP":    syscall.SIGHUP,
+	"SIGINT":    syscall.SIGINT,
+	"SIGQUIT":   syscall.SIGQUIT,
+	"SIGILL":    syscall.SIGILL,
+	"SIGTRAP":   syscall.SIGTRAP,
+	"SIGABRT":   syscall.SIGABRT,
+	"SIGIOT":    syscall.SIGIOT,
+	"SIGBUS":    syscall.SIGBUS,
+	"SIGFPE":    syscall.SIGFPE,
+	"SIGKILL":   syscall.SIGKILL,
+	"SIGUSR1":   syscall.SIGUSR1,
+	"SIGSEGV":   syscall.SIGSEGV,
+	"SIGUSR2":   syscall.SIGUSR2,
+	"SIGPIPE":   syscall.SIGPIPE,
+	"SIGALRM":   syscall.SIGALRM,
+	"SIGTERM":   syscall.SIGTERM,
+	"SIGSTKFLT": syscall.SIGSTKFLT,
+	"SIGCHLD":   syscall.SIGCHLD,
+	"SIGCLD":    syscall.SIGCLD,
+	"SIGCONT":   syscall.SIGCONT,
+	"SIGSTOP":   syscall.SIGSTOP,
+	"SIGTSTP":   syscall.SIGTSTP,
+	"SIGTTIN":   syscall.SIGTTIN,
+	"SIGTTOU":   syscall.SIGTTOU,
+	"SIGURG":    syscall.SIGURG,
+	"SIGXCPU":   syscall.SIGXCPU,
+	"SIGXFSZ":   syscall.SIGXFSZ,
+	"SIGVTALRM": syscall.SIGVTALRM,
+	"SIGPROF":   syscall.SIGPROF,
+	"SIGWINCH":  syscall.SIGWINCH,
+	"SIGIO":     syscall.SIGIO,
+	"SIGPOLL":   syscall.SIGPOLL,
+	"SIGPWR":    syscall.SIGPWR,
+	"SIGSYS":    syscall.SIGSYS,
+}
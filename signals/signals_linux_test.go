@@ -0,0 +1,91 @@
+//go:build linux
+// +build linux
+
+package utils
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestSignalsLinuxNumbers(t *testing.T) {
+	numbers := map[string]int{
+		"SIGHUP": 1, "SIGINT": 2, "SIGQUIT": 3, "SIGILL": 4, "SIGTRAP": 5,
+		"SIGABRT": 6, "SIGIOT": 6, "SIGBUS": 7, "SIGFPE": 8, "SIGKILL": 9,
+		"SIGUSR1": 10, "SIGSEGV": 11, "SIGUSR2": 12, "SIGPIPE": 13,
+		"SIGALRM": 14, "SIGTERM": 15, "SIGSTKFLT": 16, "SIGCHLD": 17,
+		"SIGCLD": 17, "SIGCONT": 18, "SIGSTOP": 19, "SIGTSTP": 20,
+		"SIGTTIN": 21, "SIGTTOU": 22, "SIGURG": 23, "SIGXCPU": 24,
+		"SIGXFSZ": 25, "SIGVTALRM": 26, "SIGPROF": 27, "SIGWINCH": 28,
+		"SIGIO": 29, "SIGPOLL": 29, "SIGPWR": 30, "SIGSYS": 31,
+		"SIGEXISTS": 0,
+	}
+	for name, n := range numbers {
+		if sig, ok := Signals[name]; !ok {
+			t.Errorf("%s missing from Signals", name)
+		} else if int(sig) != n {
+			t.Errorf("%s: expected %d, received %d", name, n, int(sig))
+		}
+	}
+	if len(Signals) != len(numbers) {
+		t.Errorf("expected %d signals, received %d", len(numbers), len(Signals))
+	}
+	for _, name := range []string{"SIGEMT", "SIGINFO"} {
+		if _, ok := Signals[name]; ok {
+			t.Errorf("%s should not exist on linux", name)
+		}
+	}
+}
+
+func TestSignalsParse(t *testing.T) {
+	valid := map[string]syscall.Signal{
+		"SIGTERM":    syscall.SIGTERM,
+		"term":       syscall.SIGTERM,
+		"Usr1":       syscall.SIGUSR1,
+		"sigchld":    syscall.SIGCHLD,
+		"9":          syscall.SIGKILL,
+		"0":          SIGEXISTS,
+		"SIGRTMIN":   syscall.Signal(34),
+		"SIGRTMIN+3": syscall.Signal(37),
+		"rtmin+30":   syscall.Signal(64),
+		"SIGRTMAX":   syscall.Signal(64),
+		"SIGRTMAX-2": syscall.Signal(62),
+		"64":         syscall.Signal(64),
+	}
+	for name, expected := range valid {
+		if sig, err := Parse(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if sig != expected {
+			t.Errorf("%s: expected %d, received %d", name, expected, sig)
+		}
+	}
+	invalid := []string{"", "SIGBOGUS", "SIG", "65", "-1", "SIGRTMIN+31",
+		"SIGRTMIN-1", "SIGRTMAX+1", "SIGRTMIN+", "SIGRTMIN+x", "SIGINFO"}
+	for _, name := range invalid {
+		if sig, err := Parse(name); err == nil {
+			t.Errorf("%q: expected error, received %d", name, sig)
+		}
+	}
+	if _, err := Parse("bogus"); err == nil || err.Error() != "unknown signal SIGBOGUS" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSignalsName(t *testing.T) {
+	names := map[syscall.Signal]string{
+		syscall.SIGTERM:    "SIGTERM",
+		syscall.SIGABRT:    "SIGABRT",
+		syscall.SIGCHLD:    "SIGCHLD",
+		syscall.SIGIO:      "SIGIO",
+		syscall.Signal(34): "SIGRTMIN",
+		syscall.Signal(37): "SIGRTMIN+3",
+		syscall.Signal(0):  "0",
+	}
+	for sig, expected := range names {
+		if name := Name(sig); name != expected {
+			t.Errorf("%d: expected %s, received %s", sig, expected, name)
+		} else if parsed, err := Parse(name); err != nil || parsed != sig {
+			t.Errorf("%s: did not parse back to %d", name, sig)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	. "github.com/Travmatth/taskmaster/job"
+	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/proc"
+	SIG "github.com/Travmatth/taskmaster/signals"
+)
+
+/*
+ * Reaper tracks the descendants of managed instances so that, once they are
+ * reparented to taskmaster, they can be attributed to their original job and
+ * reaped when they exit
+ */
+type Reaper struct {
+	Orphans map[int]string
+	lineage map[int]string
+	groups  map[int]string
+	lock    sync.Mutex
+}
+
+/*
+ * NewReaper returns a new Reaper struct
+ */
+func NewReaper() *Reaper {
+	return &Reaper{
+		Orphans: make(map[int]string),
+		lineage: make(map[int]string),
+		groups:  make(map[int]string),
+	}
+}
+
+/*
+ * Subreap registers taskmaster as a child subreaper and starts reaping
+ * orphaned descendants whenever a child changes state
+ */
+func (s *Supervisor) Subreap() error {
+	if err := EnableSubreaper(); err != nil && os.Getpid() != 1 {
+		return err
+	}
+	s.Reaper = NewReaper()
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGCHLD)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		for {
+			select {
+			case <-c:
+			case <-ticker.C:
+			}
+			s.Reap()
+		}
+	}()
+	Log.Info("Supervisor: running as child subreaper, pid", os.Getpid(),
+		Event("subreaper", "pid", os.Getpid()))
+	return nil
+}
+
+/*
+ * Reap scans the process table, recording which instance every descendant
+ * belongs to, adopting orphans reparented to taskmaster and reaping those
+ * that have exited. Only processes traced to an instance are adopted, & only
+ * adopted orphans are reaped, so that taskmaster's other children, such as
+ * an instance's process while it is replaced, are left to os.Process.Wait
+ */
+func (s *Supervisor) Reap() {
+	r := s.Reaper
+	managed := make(map[int]string)
+	s.ForAllJobs(func(job *Job) {
+		for _, instance := range job.Instances {
+			if pid := instance.PID(); pid != 0 {
+				managed[pid] = instance.String()
+			}
+		}
+	})
+	pids, err := proc.Pids()
+	if err != nil {
+		Log.Info("Supervisor: unable to scan processes:", err, Event("scan_failed"))
+		return
+	}
+	stats := make(map[int]*proc.Stat)
+	for _, pid := range pids {
+		if stat, err := proc.ReadStat(pid); err == nil {
+			stats[pid] = stat
+		}
+	}
+	defer r.lock.Unlock()
+	r.lock.Lock()
+	for pid, label := range managed {
+		r.groups[pid] = label
+	}
+	for pid, stat := range stats {
+		if _, ok := r.lineage[pid]; !ok {
+			r.trace(stat, stats, managed)
+		}
+	}
+	self := os.Getpid()
+	for pid, stat := range stats {
+		if stat.PPid != self || managed[pid] != "" {
+			continue
+		}
+		if label, ok := r.Orphans[pid]; ok {
+			if stat.State == 'Z' {
+				r.reap(pid, stat.Comm, label)
+			}
+		} else if r.descended(stat) {
+			// reaped from the next scan, once recorded as an orphan
+			label := r.attribute(stat)
+			Log.Info("Supervisor: adopted orphan", pid, stat.Comm, "from", label,
+				Event("orphan_adopted", "pid", pid, "from", label))
+			r.Orphans[pid] = label
+		}
+	}
+	for pid := range r.lineage {
+		if _, ok := stats[pid]; !ok {
+			delete(r.lineage, pid)
+		}
+	}
+}
+
+/*
+ * trace walks up the ancestors of a process until it finds the managed
+ * instance it descends from
+ */
+func (r *Reaper) trace(stat *proc.Stat,
+	stats map[int]*proc.Stat, managed map[int]string) {
+	for ppid := stat.PPid; ppid > 1; {
+		if label, ok := managed[ppid]; ok {
+			r.lineage[stat.Pid] = label
+			return
+		} else if label, ok := r.lineage[ppid]; ok {
+			r.lineage[stat.Pid] = label
+			return
+		} else if parent, ok := stats[ppid]; ok {
+			ppid = parent.PPid
+		} else {
+			return
+		}
+	}
+}
+
+/*
+ * descended reports whether a process was traced to an instance, or is in an
+ * instance's process group without leading it as the instance's own process
+ * does
+ */
+func (r *Reaper) descended(stat *proc.Stat) bool {
+	if _, ok := r.lineage[stat.Pid]; ok {
+		return true
+	}
+	_, ok := r.groups[stat.Pgrp]
+	return ok && stat.Pgrp != stat.Pid
+}
+
+/*
+ * attribute names the instance an orphan originally descended from, falling
+ * back on its process group since every instance leads its own group
+ */
+func (r *Reaper) attribute(stat *proc.Stat) string {
+	if label, ok := r.lineage[stat.Pid]; ok {
+		return label
+	} else if label, ok := r.groups[stat.Pgrp]; ok {
+		return label
+	}
+	return "unknown job"
+}
+
+/*
+ * reap collects the exit status of an exited orphan
+ */
+func (r *Reaper) reap(pid int, comm, label string) {
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
+		Log.Info("Supervisor: failed to reap orphan", pid, ":", err,
+			Event("reap_failed", "pid", pid, "from", label))
+	} else {
+		message := "Supervisor: reaped orphan"
+		Log.Info(message, pid, comm, "from", label, "with", formatWait(status),
+			Event("orphan_reaped", append([]interface{}{"pid", pid, "from", label},
+				waitFields(status)...)...))
+	}
+	delete(r.Orphans, pid)
+	delete(r.lineage, pid)
+}
+
+/*
+ * StopOrphans forwards SIGTERM to adopted orphans still running, issuing a
+ * SIGKILL to any that remain after the timeout
+ */
+func (s *Supervisor) StopOrphans(timeout time.Duration) {
+	if s.Reaper == nil {
+		return
+	}
+	s.signalOrphans(syscall.SIGTERM)
+	end := time.Now().Add(timeout)
+	for time.Now().Before(end) {
+		if s.signalOrphans(syscall.Signal(0)) == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		s.Reap()
+	}
+	s.signalOrphans(syscall.SIGKILL)
+}
+
+/*
+ * signalOrphans sends sig to every adopted orphan, returning the number of
+ * orphans being tracked
+ */
+func (s *Supervisor) signalOrphans(sig syscall.Signal) int {
+	defer s.Reaper.lock.Unlock()
+	s.Reaper.lock.Lock()
+	for pid, label := range s.Reaper.Orphans {
+		if sig != 0 {
+			Log.Info("Supervisor: sending", sig, "to orphan", pid, "from", label,
+				Event("signal_sent", "pid", pid, "from", label, "signal", SIG.Name(sig)))
+		}
+		syscall.Kill(pid, sig)
+	}
+	return len(s.Reaper.Orphans)
+}
+
+/*
+ * formatWait returns the printable representation of a wait status
+ */
+func formatWait(status syscall.WaitStatus) string {
+	if status.Signaled() {
+		return fmt.Sprintf("signal: %v", status.Signal())
+	}
+	return fmt.Sprintf("exit status %d", status.ExitStatus())
+}
+
+/*
+ * waitFields returns the exit code, or the signal, of a wait status as pairs
+ * of keys & values for structured logs
+ */
+func waitFields(status syscall.WaitStatus) []interface{} {
+	if status.Signaled() {
+		return []interface{}{"exit_code", -1, "signal", SIG.Name(status.Signal())}
+	}
+	return []interface{}{"exit_code", status.ExitStatus()}
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package supervisor
+
+import (
+	"syscall"
+)
+
+// PR_SET_CHILD_SUBREAPER from <linux/prctl.h>
+const prSetChildSubreaper = 36
+
+/*
+ * EnableSubreaper marks taskmaster as a child subreaper, so that orphaned
+ * descendants are reparented to it instead of to init, see `man 2 prctl`
+ */
+func EnableSubreaper() error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL,
+		prSetChildSubreaper, 1, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
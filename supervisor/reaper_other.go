@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package supervisor
+
+import (
+	"fmt"
+)
+
+/*
+ * EnableSubreaper is only supported on linux
+ */
+func EnableSubreaper() error {
+	return fmt.Errorf("Supervisor Error: subreaper mode requires linux")
+}
@@ -0,0 +1,157 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/Travmatth/taskmaster/job"
+	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/proc"
+	SIG "github.com/Travmatth/taskmaster/signals"
+)
+
+/*
+ * InstanceState records a running instance so that a later taskmaster can
+ * re-attach to it. ProcStart is the start time from /proc/<pid>/stat, used to
+ * detect the pid having been reused by an unrelated process, & TermSignal the
+ * signal which terminated the instance's previous process, if any
+ */
+type InstanceState struct {
+	Job        int       `json:"Job"`
+	Instance   int       `json:"Instance"`
+	PID        int       `json:"PID"`
+	StartTime  time.Time `json:"StartTime"`
+	ProcStart  uint64    `json:"ProcStart"`
+	TermSignal string    `json:"TermSignal,omitempty"`
+}
+
+/*
+ * State is the content of the state file. Fds maps the files handed over
+ * during an upgrade to their descriptor numbers
+ */
+type State struct {
+	Instances []InstanceState `json:"Instances"`
+	Fds       map[string]int  `json:"Fds,omitempty"`
+}
+
+/*
+ * CollectState snapshots the running instances of all managed jobs, along
+ * with the signal which terminated their previous process
+ */
+func (s *Supervisor) CollectState() *State {
+	state := &State{Instances: []InstanceState{}}
+	s.ForAllJobs(func(job *Job) {
+		for _, instance := range job.Instances {
+			if !instance.Running() {
+				continue
+			}
+			instance.Mutex.RLock()
+			record := InstanceState{
+				Job:       job.ID,
+				Instance:  instance.InstanceID,
+				PID:       instance.Process.Pid,
+				StartTime: instance.StartTime,
+				ProcStart: instance.ProcStart,
+			}
+			if sig, ok := instance.TermSignal.(syscall.Signal); ok {
+				record.TermSignal = SIG.Name(sig)
+			}
+			state.Instances = append(state.Instances, record)
+			instance.Mutex.RUnlock()
+		}
+	})
+	return state
+}
+
+/*
+ * SaveState writes the state file, replacing it atomically
+ */
+func (s *Supervisor) SaveState() error {
+	if s.StateFile == "" {
+		return nil
+	}
+	buf, err := json.MarshalIndent(s.CollectState(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.StateFile)
+}
+
+/*
+ * PersistState saves the state file every interval for as long as
+ * taskmaster runs
+ */
+func (s *Supervisor) PersistState(interval time.Duration) {
+	for {
+		if err := s.SaveState(); err != nil {
+			Log.Info("Supervisor: failed to save state:", err, Event("state_failed"))
+		}
+		time.Sleep(interval)
+	}
+}
+
+/*
+ * LoadState reads a state file written by SaveState
+ */
+func LoadState(file string) (*State, error) {
+	var state State
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+/*
+ * AdoptInstances re-attaches the given jobs' instances to the processes
+ * recorded in state which are still running, instead of launching them anew
+ */
+func AdoptInstances(jobs []*Job, state *State) {
+	byID := make(map[int]*Job)
+	for _, job := range jobs {
+		byID[job.ID] = job
+	}
+	for _, record := range state.Instances {
+		job, ok := byID[record.Job]
+		if !ok || record.Instance >= len(job.Instances) {
+			message := "Supervisor: no instance to adopt"
+			Log.Info(message, record.PID, "for Job", record.Job,
+				Event("not_adopted", "job", record.Job, "pid", record.PID))
+			continue
+		}
+		instance := job.Instances[record.Instance]
+		stat, err := proc.ReadStat(record.PID)
+		if err == nil && stat.State == 'Z' && stat.PPid == os.Getpid() {
+			// exited while taskmaster was being re-executed
+			var status syscall.WaitStatus
+			syscall.Wait4(record.PID, &status, syscall.WNOHANG, nil)
+			Log.Info(instance, ": recorded process", record.PID,
+				"exited with", formatWait(status), "during upgrade",
+				Event("exited", append([]interface{}{"pid", record.PID},
+					waitFields(status)...)...))
+			continue
+		} else if err != nil || stat.State == 'Z' ||
+			stat.StartTime != record.ProcStart {
+			Log.Info(instance, ": recorded process", record.PID, "no longer running",
+				Event("not_adopted", "pid", record.PID))
+			continue
+		}
+		if sig, err := SIG.Parse(record.TermSignal); err == nil {
+			// the signal which ended the process's predecessor
+			instance.Mutex.Lock()
+			instance.TermSignal = sig
+			instance.Mutex.Unlock()
+		}
+		instance.Adopt(record.PID, record.ProcStart, record.StartTime)
+	}
+}
@@ -3,21 +3,28 @@ package supervisor
 import (
 	"os"
 	"sync"
+	"time"
 
 	. "github.com/Travmatth/taskmaster/job"
 	. "github.com/Travmatth/taskmaster/log"
+	"github.com/Travmatth/taskmaster/pty"
 )
 
 /*
  * Supervisor models the users manipulation of jobs
  */
 type Supervisor struct {
-	Config  string
-	LogFile string
-	Mgr     *Manager
-	lock    sync.Mutex
-	restart bool
-	SigCh   chan os.Signal
+	Config    string
+	LogFile   string
+	StateFile string
+	Mgr       *Manager
+	lock      sync.Mutex
+	restart   bool
+	SigCh     chan os.Signal
+	Reaper    *Reaper
+	files     map[string]*os.File
+	inherited map[string]*os.File
+	interrupt func()
 }
 
 /*
@@ -33,6 +40,37 @@ func NewSupervisor(cfg, logFile string,
 	}
 }
 
+/*
+ * InterceptInterrupt makes SIGINT call f instead of shutting taskmaster down,
+ * such as to stop following output in the ui, until the returned function is
+ * called
+ */
+func (s *Supervisor) InterceptInterrupt(f func()) func() {
+	s.lock.Lock()
+	s.interrupt = f
+	s.lock.Unlock()
+	return func() {
+		s.lock.Lock()
+		s.interrupt = nil
+		s.lock.Unlock()
+	}
+}
+
+/*
+ * Interrupt calls the function intercepting SIGINT, returning false if there
+ * is none
+ */
+func (s *Supervisor) Interrupt() bool {
+	s.lock.Lock()
+	f := s.interrupt
+	s.lock.Unlock()
+	if f == nil {
+		return false
+	}
+	f()
+	return true
+}
+
 /*
  * DiffJobs sorts the given jobs into current, old, changed, and new slices
  */
@@ -42,15 +80,17 @@ func (s *Supervisor) DiffJobs(jobs []*Job) ([]*Job, []*Job, []*Job, []*Job) {
 	current, old, changed, new := []*Job{}, []*Job{}, []*Job{}, []*Job{}
 	for _, reloaded := range jobs {
 		if job, ok := s.Mgr.Jobs[reloaded.ID]; !ok {
-			Log.Info("Supervisor diffing next jobs: new", reloaded)
+			Log.Info("Supervisor diffing next jobs: new", reloaded, Event("job_added"))
 			new = append(new, reloaded)
 		} else if diff := reloaded.Cfg.Same(job.Cfg); !diff {
-			Log.Info("Supervisor diffing next jobs: changed", reloaded)
+			Log.Info("Supervisor diffing next jobs: changed", reloaded,
+				Event("job_changed"))
 			changed = append(changed, job)
 			new = append(new, reloaded)
 			s.Mgr.RemoveJob(job.ID)
 		} else {
-			Log.Info("Supervisor diffing next jobs: current", job)
+			Log.Info("Supervisor diffing next jobs: current", job,
+				Event("job_unchanged"))
 			current = append(current, job)
 			s.Mgr.RemoveJob(job.ID)
 		}
@@ -71,7 +111,18 @@ func (s *Supervisor) Reload(jobs []*Job, wait bool) error {
 	for _, job := range append(old, changed...) {
 		job.Stop(wait)
 	}
+	for _, job := range old {
+		job.CloseSockets()
+	}
+	for _, job := range changed {
+		for _, reloaded := range next {
+			if reloaded.ID == job.ID {
+				reloaded.TakeSockets(job)
+			}
+		}
+	}
 	s.AddMultiJobs(append(current, next...))
+	s.LinkDependencies()
 	for _, job := range next {
 		if job.AtLaunch {
 			job.Start(wait)
@@ -89,6 +140,25 @@ func (s *Supervisor) AddMultiJobs(jobs []*Job) {
 	s.Mgr.AddMultiJobs(jobs)
 }
 
+/*
+ * LinkDependencies points each job at the managed jobs it depends on
+ */
+func (s *Supervisor) LinkDependencies() {
+	defer s.Mgr.lock.Unlock()
+	s.Mgr.lock.Lock()
+	for _, job := range s.Mgr.Jobs {
+		job.Dependencies = nil
+		for _, id := range job.DependsOn {
+			if dep, ok := s.Mgr.Jobs[id]; ok {
+				job.Dependencies = append(job.Dependencies, dep)
+			} else {
+				Log.Info("Supervisor:", job, "depends on unknown job", id,
+					Event("unknown_dependency", "dependency", id))
+			}
+		}
+	}
+}
+
 /*
  * WaitForExit waits for exit
  */
@@ -107,6 +177,18 @@ func (s *Supervisor) StartJob(id int, wait bool) error {
 	return err
 }
 
+/*
+ * WaitJob waits for every instance of a given job to finish running,
+ * returning its exit code
+ */
+func (s *Supervisor) WaitJob(id int) (int, error) {
+	job, err := s.Mgr.GetJob(id)
+	if err != nil {
+		return -1, err
+	}
+	return job.WaitForCompletion(), nil
+}
+
 /*
  * StopJob retrieves & stops a given job
  */
@@ -118,6 +200,54 @@ func (s *Supervisor) StopJob(id int) error {
 	return err
 }
 
+/*
+ * PauseJob pauses the given instance of a job, or all of its instances if
+ * instance is -1
+ */
+func (s *Supervisor) PauseJob(id, instance int) error {
+	job, err := s.Mgr.GetJob(id)
+	if err == nil {
+		err = job.Pause(instance)
+	}
+	return err
+}
+
+/*
+ * ResumeJob resumes the given instance of a job, or all of its instances if
+ * instance is -1
+ */
+func (s *Supervisor) ResumeJob(id, instance int) error {
+	job, err := s.Mgr.GetJob(id)
+	if err == nil {
+		err = job.Resume(instance)
+	}
+	return err
+}
+
+/*
+ * SignalJob sends a signal to the given instance of a job, or all of its
+ * running instances if instance is -1
+ */
+func (s *Supervisor) SignalJob(id, instance int, sig os.Signal, group bool) error {
+	job, err := s.Mgr.GetJob(id)
+	if err == nil {
+		err = job.Signal(instance, sig, group)
+	}
+	return err
+}
+
+/*
+ * ResizeJob sets the terminal window size of the given instance of a job,
+ * or of all of its instances if instance is -1
+ */
+func (s *Supervisor) ResizeJob(id, instance int, size pty.Size) error {
+	job, err := s.Mgr.GetJob(id)
+	if err == nil {
+		err = job.Resize(instance, size)
+	}
+	return err
+}
+
 /*
  * GetJob returns the job with the given id
  */
@@ -167,6 +297,56 @@ func (s *Supervisor) StopAllJobs(wait bool) {
 	}
 }
 
+/*
+ * ReopenLogs reopens taskmaster's log along with the files the output of
+ * every instance is written to, returning the first error met while still
+ * reopening the rest
+ */
+func (s *Supervisor) ReopenLogs() error {
+	err := ReopenLog()
+	s.ForAllJobs(func(job *Job) {
+		for _, instance := range job.Instances {
+			if e := instance.ReopenLogs(); e != nil {
+				Log.Info(instance, ": unable to reopen log files:", e,
+					Event("reopen_failed"))
+				if err == nil {
+					err = e
+				}
+			}
+		}
+	})
+	Log.Info("Supervisor: reopened log files", Event("logs_reopened"))
+	return err
+}
+
+/*
+ * SetLogLevels changes the levels logged while running, given as taken by
+ * SetLevels, e.g. "INFO instance=DEBUG", returning the levels now logged.
+ * An empty spec leaves them unchanged
+ */
+func (s *Supervisor) SetLogLevels(spec string) (string, error) {
+	if spec == "" {
+		return Levels(), nil
+	} else if err := SetLevels(spec); err != nil {
+		return Levels(), err
+	}
+	Log.Info("Supervisor: log level set to", Levels(),
+		Event("log_level", "levels", Levels()))
+	return Levels(), nil
+}
+
+/*
+ * Shutdown stops all jobs, along with any orphans adopted by the subreaper,
+ * and records the final state
+ */
+func (s *Supervisor) Shutdown() {
+	s.StopAllJobs(true)
+	s.StopOrphans(5 * time.Second)
+	if err := s.SaveState(); err != nil {
+		Log.Info("Supervisor: failed to save state:", err, Event("state_failed"))
+	}
+}
+
 /*
  * HasJob returns number of jobs being managed
  */
@@ -1,7 +1,10 @@
 package supervisor
 
 import (
+	"errors"
 	"os"
+	"strconv"
+	"syscall"
 	"testing"
 
 	JOB "github.com/Travmatth/taskmaster/job"
@@ -18,6 +21,57 @@ func processJobsFromFiles(files ...string) []*JOB.Job {
 	return total
 }
 
+func closeOnExec(fd int) (bool, error) {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL,
+		uintptr(fd), syscall.F_GETFD, 0)
+	if errno != 0 {
+		return false, errno
+	}
+	return flags&syscall.FD_CLOEXEC != 0, nil
+}
+
+func TestSupervisorUpgradeFailureRestoresCloseOnExec(t *testing.T) {
+	MockLogger("buf")
+	ch := make(chan os.Signal)
+	s := NewSupervisor("", "", NewManager(), ch)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	s.Inherit("pipe", r)
+	state := -1
+	defer func(orig func(string, []string, []string) error) {
+		execve = orig
+	}(execve)
+	execve = func(exe string, args []string, env []string) error {
+		fd, err := rawFd(r)
+		if err != nil {
+			return err
+		} else if set, err := closeOnExec(fd); err != nil || set {
+			t.Error("Error: registered file should be inherited across exec")
+		}
+		state, _ = strconv.Atoi(args[len(args)-1])
+		return errors.New("exec failed")
+	}
+	if err := s.Upgrade(); err == nil || err.Error() != "exec failed" {
+		t.Fatal("Error: Upgrade should return the exec error, actually", err)
+	}
+	fd, err := rawFd(r)
+	if err != nil {
+		t.Fatal(err)
+	} else if set, err := closeOnExec(fd); err != nil || !set {
+		t.Error("Error: close on exec should be restored after failed upgrade")
+	}
+	if state == -1 {
+		t.Error("Error: Upgrade should pass the state descriptor to exec")
+	} else if _, err := closeOnExec(state); err != syscall.EBADF {
+		t.Error("Error: state file should be closed after failed upgrade")
+	}
+	Buf.Reset()
+}
+
 func TestSupervisorDiffJobs(t *testing.T) {
 	ch := make(chan os.Signal)
 	s := NewSupervisor("", "", NewManager(), ch)
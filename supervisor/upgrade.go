@@ -0,0 +1,238 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+
+	INST "github.com/Travmatth/taskmaster/instance"
+	. "github.com/Travmatth/taskmaster/job"
+	. "github.com/Travmatth/taskmaster/log"
+)
+
+/*
+ * UPGRADEFLAG passes the descriptor holding the serialized state to the
+ * re-executed taskmaster
+ */
+const UPGRADEFLAG = "--upgrade-fd"
+
+// execve replaces the running binary, swapped out by tests
+var execve = syscall.Exec
+
+/*
+ * Inherit registers a file, such as a listening socket, to be handed over to
+ * the new taskmaster on upgrade
+ */
+func (s *Supervisor) Inherit(name string, f *os.File) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	if s.files == nil {
+		s.files = make(map[string]*os.File)
+	}
+	s.files[name] = f
+}
+
+/*
+ * Inherited returns the file registered under name by the taskmaster that
+ * upgraded into this one, if any
+ */
+func (s *Supervisor) Inherited(name string) *os.File {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	f := s.inherited[name]
+	delete(s.inherited, name)
+	return f
+}
+
+/*
+ * Upgrade serializes the managed state into an inherited descriptor along
+ * with any registered files and re-executes the taskmaster binary, which
+ * resumes supervising the running children without restarting them. Only
+ * returns if the upgrade could not be performed, in which case every
+ * descriptor is made close on exec again so later children do not inherit it
+ */
+func (s *Supervisor) Upgrade() (err error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	s.ForAllJobs(func(job *Job) {
+		for _, socket := range job.Sockets {
+			if socket.File != nil {
+				s.Inherit(socket.Key(job.ID), socket.File)
+			}
+		}
+		for _, instance := range job.Instances {
+			s.inheritOutput(instance)
+		}
+	})
+	var cleared []int
+	defer func() {
+		if err != nil {
+			for _, fd := range cleared {
+				syscall.CloseOnExec(fd)
+			}
+		}
+	}()
+	state := s.CollectState()
+	state.Fds = make(map[string]int)
+	s.lock.Lock()
+	for name, file := range s.files {
+		fd, err := rawFd(file)
+		if err == nil {
+			err = clearCloseOnExec(fd)
+		}
+		if err != nil {
+			s.lock.Unlock()
+			return err
+		}
+		cleared = append(cleared, fd)
+		state.Fds[name] = fd
+	}
+	s.lock.Unlock()
+	f, err := ioutil.TempFile("", "taskmaster-upgrade")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+	fd, err := rawFd(f)
+	if err != nil {
+		return err
+	} else if err := json.NewEncoder(f).Encode(state); err != nil {
+		return err
+	} else if _, err := f.Seek(0, 0); err != nil {
+		return err
+	} else if err := clearCloseOnExec(fd); err != nil {
+		return err
+	}
+	cleared = append(cleared, fd)
+	args := []string{}
+	for n := 0; n < len(os.Args); n++ {
+		if os.Args[n] == UPGRADEFLAG {
+			n++
+			continue
+		}
+		args = append(args, os.Args[n])
+	}
+	args = append(args, UPGRADEFLAG, strconv.Itoa(fd))
+	Log.Info("Supervisor: upgrading, re-executing", exe, Event("upgrading"))
+	return execve(exe, args, os.Environ())
+}
+
+/*
+ * ResumeUpgrade reads the state handed over by the previous taskmaster,
+ * taking ownership of the files it registered
+ */
+func (s *Supervisor) ResumeUpgrade(fd int) (*State, error) {
+	var state State
+	f := os.NewFile(uintptr(fd), "upgrade")
+	if f == nil {
+		return nil, fmt.Errorf("Supervisor Error: invalid upgrade fd %d", fd)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&state); err != nil {
+		return nil, err
+	}
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	s.inherited = make(map[string]*os.File)
+	for name, fd := range state.Fds {
+		syscall.CloseOnExec(fd)
+		s.inherited[name] = os.NewFile(uintptr(fd), name)
+	}
+	return &state, nil
+}
+
+/*
+ * ResumeSockets hands the listening sockets inherited from the previous
+ * taskmaster back to the jobs which own them
+ */
+func (s *Supervisor) ResumeSockets(jobs []*Job) {
+	for _, job := range jobs {
+		for _, socket := range job.Sockets {
+			if f := s.Inherited(socket.Key(job.ID)); f != nil {
+				socket.File = f
+			}
+		}
+	}
+}
+
+/*
+ * outputKey identifies a pipe to an instance when it is handed over during an
+ * upgrade, stream 0 being its input & 1, 2 its output
+ */
+func outputKey(instance *INST.Instance, stream int) string {
+	return fmt.Sprintf("output %d %d %d", instance.JobID, instance.InstanceID, stream)
+}
+
+/*
+ * inheritOutput registers the pipes of a running instance, so that its
+ * output is still captured after the upgrade & it does not receive SIGPIPE
+ */
+func (s *Supervisor) inheritOutput(instance *INST.Instance) {
+	stdin, captured := instance.CapturedFiles()
+	if stdin != nil && (len(captured) == 0 || stdin != captured[0]) {
+		s.Inherit(outputKey(instance, 0), stdin)
+	}
+	for n, f := range captured {
+		s.Inherit(outputKey(instance, n+1), f)
+	}
+}
+
+/*
+ * ResumeOutput hands the pipes inherited from the previous taskmaster back to
+ * the instances which own them
+ */
+func (s *Supervisor) ResumeOutput(jobs []*Job) {
+	for _, job := range jobs {
+		for _, instance := range job.Instances {
+			stdin := s.Inherited(outputKey(instance, 0))
+			var captured []*os.File
+			for n := 1; ; n++ {
+				f := s.Inherited(outputKey(instance, n))
+				if f == nil {
+					break
+				}
+				captured = append(captured, f)
+			}
+			if stdin == nil && captured == nil {
+				continue
+			} else if err := instance.ResumeCapture(stdin, captured); err != nil {
+				Log.Info(instance, ": unable to resume capturing output:", err,
+					Event("capture_failed"))
+			}
+		}
+	}
+}
+
+/*
+ * rawFd returns the descriptor of f without putting it in blocking mode, as
+ * f.Fd() does
+ */
+func rawFd(f *os.File) (int, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	fd := -1
+	err = conn.Control(func(p uintptr) {
+		fd = int(p)
+	})
+	return fd, err
+}
+
+/*
+ * clearCloseOnExec lets the descriptor survive the exec
+ */
+func clearCloseOnExec(fd int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL,
+		uintptr(fd), syscall.F_SETFD, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
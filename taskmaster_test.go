@@ -1,17 +1,34 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	// . "github.com/Travmatth/taskmaster/ui"
 	// . "github.com/Travmatth/taskmaster/log"
 	// . "github.com/Travmatth/taskmaster/signals"
+	INST "github.com/Travmatth/taskmaster/instance"
+	JOB "github.com/Travmatth/taskmaster/job"
+	LOG "github.com/Travmatth/taskmaster/log"
 	. "github.com/Travmatth/taskmaster/parse"
+	"github.com/Travmatth/taskmaster/proc"
 	. "github.com/Travmatth/taskmaster/supervisor"
+	UI "github.com/Travmatth/taskmaster/ui"
 	. "github.com/Travmatth/taskmaster/utils"
+	"github.com/op/go-logging"
 )
 
 func PrepareSupervisor(t *testing.T, file string) *Supervisor {
@@ -30,6 +47,9 @@ func TestMain(m *testing.M) {
 	flag.StringVar(&logOut, "logs", "buf", "Log file output")
 	flag.Parse()
 	MockLogger(logOut)
+	// the processes launched are traced at DEBUG, which the logs expected
+	// by these tests leave out
+	MockLevel(logging.INFO)
 	os.Exit(m.Run())
 }
 
@@ -284,6 +304,55 @@ func TestTaskMasterKillAfterIgnoredStopSignal(t *testing.T) {
 	Buf.Reset()
 }
 
+func TestTaskMasterKillRestartedInstance(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/KillRestartedInstance.yaml")
+	go func() {
+		j, _ := s.Mgr.GetJob(43)
+		if err := s.StartJob(43, true); err != nil {
+			ch <- err
+			return
+		}
+		// the exit of the first run must not stop the restarted process
+		// being escalated to SIGKILL
+		inst := j.Instances[0]
+		inst.Mutex.RLock()
+		first := inst.Process
+		inst.Mutex.RUnlock()
+		for {
+			inst.Mutex.RLock()
+			restarted := inst.Process != first && inst.Status == INST.PROCRUNNING
+			inst.Mutex.RUnlock()
+			// the script ignores the stop signal once it removes its file
+			_, err := os.Stat("test_scripts/ignore_after_restart_tmp")
+			if restarted && os.IsNotExist(err) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		ch <- s.StopJob(43)
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 43 Instance 0 : Successfully Started with no start checkup",
+				"Job 43 Instance 0 : exited with status: exit status 1",
+				"Job 43 Instance 0 : Successfully Started with no start checkup",
+				"Job 43 Instance 0 : Sending Signal interrupt",
+				"Job 43 Instance 0 : did not stop after timeout of  1 seconds SIGKILL issued",
+				"Job 43 Instance 0 : exited with status: signal: killed",
+				"Job 43 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestKillRestartedInstance timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
 func TestTaskMasterRedirectStdout(t *testing.T) {
 	testFile := "test_scripts/RedirectStdout.test"
 	ch := make(chan struct{})
@@ -473,3 +542,1309 @@ func TestTaskMasterStartStopMultipleInstances(t *testing.T) {
 	}
 	Buf.Reset()
 }
+
+func TestTaskMasterStopSequence(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/StopSequence.yaml")
+	go func() {
+		if err := s.StartJob(20, true); err != nil {
+			ch <- err
+		} else if err = s.StopJob(20); err != nil {
+			ch <- err
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		j, _ := s.Mgr.GetJob(20)
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else if sig := j.Instances[0].TermSignal; sig != syscall.SIGKILL {
+			t.Errorf("Error: TermSignal should be SIGKILL, actually %v", sig)
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 20 Instance 0 : Successfully Started after 1 second(s)",
+				"Job 20 Instance 0 : Sending Signal terminated",
+				"Job 20 Instance 0 : did not stop after 1s escalating to interrupt",
+				"Job 20 Instance 0 : Sending Signal interrupt",
+				"Job 20 Instance 0 : did not stop after timeout of  1 seconds SIGKILL issued",
+				"Job 20 Instance 0 : exited with status: signal: killed",
+				"Job 20 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestStopSequence timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterAdoptRunningInstance(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Adopt.yaml")
+	// launch a process taskmaster is not the parent of
+	script := "/bin/sleep 30 >/dev/null 2>&1 & echo $!"
+	out, err := exec.Command("/bin/sh", "-c", script).Output()
+	if err != nil {
+		t.Fatal("Error: unable to launch process to adopt:", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	stat, err := proc.ReadStat(pid)
+	if err != nil {
+		t.Fatal("Error: unable to read process to adopt:", err)
+	}
+	j, _ := s.Mgr.GetJob(21)
+	go func() {
+		AdoptInstances(s.Mgr.GetAllJobs(0), &State{
+			Instances: []InstanceState{{
+				Job:        21,
+				Instance:   0,
+				PID:        pid,
+				StartTime:  time.Now(),
+				ProcStart:  stat.StartTime,
+				TermSignal: "SIGKILL",
+			}},
+		})
+		for j.Instances[0].GetStatus() != "running" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if state := s.CollectState(); len(state.Instances) != 1 ||
+			state.Instances[0].PID != pid {
+			ch <- fmt.Errorf("state should record adopted pid %d", pid)
+		} else if sig := state.Instances[0].TermSignal; sig != "SIGKILL" {
+			ch <- fmt.Errorf("state should keep the terminating signal, recorded %q", sig)
+		} else if ps := UI.NewFrontend(s).FormatJobs(); !strings.Contains(ps, "killed by SIGKILL") {
+			ch <- fmt.Errorf("ps should show the terminating signal:\n%s", ps)
+		} else {
+			ch <- s.StopJob(21)
+		}
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				fmt.Sprintf("Job 21 Instance 0 : adopted running process %d", pid),
+				"Job 21 Instance 0 : Successfully Started with no start checkup",
+				"Job 21 Instance 0 : Sending Signal terminated",
+				fmt.Sprintf("Job 21 Instance 0 : adopted process %d exited", pid),
+				"Job 21 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		syscall.Kill(pid, syscall.SIGKILL)
+		t.Errorf("TestAdoptRunningInstance timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterAdoptWarnsOutputNotCaptured(t *testing.T) {
+	ch := make(chan error)
+	defer os.Remove("test_scripts/AdoptCaptured.test")
+	s := PrepareSupervisor(t, "procfiles/AdoptCaptured.yaml")
+	script := "/bin/sleep 30 >/dev/null 2>&1 & echo $!"
+	out, err := exec.Command("/bin/sh", "-c", script).Output()
+	if err != nil {
+		t.Fatal("Error: unable to launch process to adopt:", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	stat, err := proc.ReadStat(pid)
+	if err != nil {
+		t.Fatal("Error: unable to read process to adopt:", err)
+	}
+	j, _ := s.Mgr.GetJob(45)
+	go func() {
+		AdoptInstances(s.Mgr.GetAllJobs(0), &State{
+			Instances: []InstanceState{{
+				Job:       45,
+				Instance:  0,
+				PID:       pid,
+				StartTime: time.Now(),
+				ProcStart: stat.StartTime,
+			}},
+		})
+		for j.Instances[0].GetStatus() != "running" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		ch <- s.StopJob(45)
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				fmt.Sprintf("Job 45 Instance 0 : adopted running process %d", pid),
+				fmt.Sprintf("Job 45 Instance 0 : output of adopted process %d is no longer captured, restart it to capture its output", pid),
+				"Job 45 Instance 0 : Successfully Started with no start checkup",
+				"Job 45 Instance 0 : Sending Signal terminated",
+				fmt.Sprintf("Job 45 Instance 0 : adopted process %d exited", pid),
+				"Job 45 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		syscall.Kill(pid, syscall.SIGKILL)
+		t.Errorf("TestAdoptWarnsOutputNotCaptured timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterScheduledJob(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Schedule.yaml")
+	j, _ := s.Mgr.GetJob(22)
+	go func() {
+		s.StartAllJobs(true)
+		if next := j.NextRun(); next.Hour() != 3 || next.Minute() != 0 {
+			ch <- fmt.Errorf("next run should be at 03:00, actually %v", next)
+			return
+		} else if !j.Instances[0].Idle() {
+			ch <- fmt.Errorf("scheduled job should not start before its tick")
+			return
+		}
+		j.Instances[0].StartInstance(true)
+		j.Instances[0].WaitForIdle()
+		s.StopAllJobs(true)
+		if runs := j.Runs(); len(runs) != 1 || runs[0].ExitCode != 0 {
+			ch <- fmt.Errorf("history should record one run, actually %v", runs)
+		} else if !j.NextRun().IsZero() {
+			ch <- fmt.Errorf("stopped job should have no next run")
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 22 : scheduled with 0 3 * * *",
+				"Job 22 Instance 0 : Successfully Started with no start checkup",
+				"Job 22 Instance 0 : exited with status: exit status 0",
+				"Job 22 Instance 0 : restart policy specifies do not restart",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestScheduledJob timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterOneshotDependency(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Oneshot.yaml")
+	s.LinkDependencies()
+	go func() {
+		s.StartJob(24, false)
+		s.StartJob(23, true)
+		if code, err := s.WaitJob(23); err != nil {
+			ch <- err
+			return
+		} else if code != 0 {
+			ch <- fmt.Errorf("oneshot should finish with code 0, actually %d", code)
+			return
+		}
+		j, _ := s.Mgr.GetJob(24)
+		for j.Instances[0].GetStatus() != "running" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		ch <- s.StopJob(24)
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 24 : waiting for Job 23 to complete",
+				"Job 23 Instance 0 : Successfully Started with no start checkup",
+				"Job 23 Instance 0 : exited with status: exit status 3",
+				"Job 23 Instance 0 : oneshot failed with exit code 3 , retry 1 of 1",
+				"Job 23 Instance 0 : Successfully Started with no start checkup",
+				"Job 23 Instance 0 : exited with status: exit status 0",
+				"Job 23 Instance 0 : oneshot succeeded with exit code 0",
+				"Job 24 Instance 0 : Successfully Started with no start checkup",
+				"Job 24 Instance 0 : Sending Signal interrupt",
+				"Job 24 Instance 0 : exited with status: signal: interrupt",
+				"Job 24 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestOneshotDependency timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterWaitExitCode(t *testing.T) {
+	sock := "test_scripts/WaitExitCode.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/WaitExitCode.yaml")
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		var out bytes.Buffer
+		err := UI.Control(sock, "start 41 --wait", nil, &out)
+		expected := "Starting 41 and waiting for it to finish\n" +
+			"Job 41 finished with exit code 3\n"
+		if exit, ok := err.(*UI.ExitError); !ok || exit.Code != 3 {
+			ch <- fmt.Errorf("expected exit status 3, received %v", err)
+		} else if out.String() != expected {
+			ch <- fmt.Errorf("expected %q, received %q", expected, out.String())
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 41 Instance 0 : Successfully Started with no start checkup",
+				"Job 41 Instance 0 : exited with status: exit status 3",
+				"Job 41 Instance 0 : oneshot failed with exit code 3",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestWaitExitCode timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterDiscardOutput(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/DiscardOutput.yaml")
+	go func() {
+		if err := s.StartJob(42, true); err != nil {
+			ch <- err
+			return
+		}
+		// output nobody reads goes to /dev/null, not through a pipe which
+		// would kill the process once taskmaster exits
+		j, _ := s.Mgr.GetJob(42)
+		var err error
+		for _, fd := range []string{"1", "2"} {
+			path := fmt.Sprintf("/proc/%d/fd/%s", j.Instances[0].Process.Pid, fd)
+			if dest, e := os.Readlink(path); e != nil || dest != os.DevNull {
+				err = fmt.Errorf("expected fd %s on %s, found %q %v", fd, os.DevNull, dest, e)
+			}
+		}
+		if e := s.StopJob(42); err == nil {
+			err = e
+		}
+		ch <- err
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 42 Instance 0 : Successfully Started with no start checkup",
+				"Job 42 Instance 0 : Sending Signal terminated",
+				"Job 42 Instance 0 : exited with status: signal: terminated",
+				"Job 42 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestDiscardOutput timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterControlSocket(t *testing.T) {
+	sock := "test_scripts/ControlSocket.sock"
+	s := PrepareSupervisor(t, "procfiles/WaitExitCode.yaml")
+	// a socket left behind by a taskmaster which exited is replaced
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatalf("Error: a stale socket should be replaced: %s", err)
+	}
+	defer listener.Close()
+	if info, err := os.Stat(sock); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Error: the socket should only be accessible to its owner: %v %v", info.Mode(), err)
+	}
+	if second, err := UI.ServeControl(s, sock); err == nil {
+		second.Close()
+		t.Errorf("Error: a socket still listening should not be replaced")
+	}
+	var out bytes.Buffer
+	if err := UI.Control(sock, "loglevel", nil, &out); err != nil {
+		t.Errorf("Error: the first taskmaster should keep its socket: %s", err)
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterSocketActivation(t *testing.T) {
+	testFile := "test_scripts/SocketActivation.test"
+	sock := "test_scripts/SocketActivation.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/SocketActivation.yaml")
+	j, _ := s.Mgr.GetJob(25)
+	go func() {
+		s.StartJob(25, false)
+		if info, err := os.Stat(sock); err != nil {
+			ch <- err
+			return
+		} else if info.Mode().Perm() != 0600 {
+			ch <- fmt.Errorf("socket should have mode 0600, actually %v", info.Mode())
+			return
+		} else if !j.Instances[0].Idle() {
+			ch <- fmt.Errorf("lazy job should not start before a connection")
+			return
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer conn.Close()
+		<-j.Instances[0].FinishedCh
+		s.StopJob(25)
+		j.CloseSockets()
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else if contents, err := FileContains(testFile); err != nil {
+			t.Errorf("Error: file error\n%s\nlogs:%s", err, logs)
+		} else if contents != "1 web pid socket" {
+			t.Errorf("Error: incorrect string\n%s\nlogs:%s", contents, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 25 : listening on unix test_scripts/SocketActivation.sock",
+				"Job 25 : waiting for a connection to start",
+				"Job 25 : connection received, activating",
+				"Job 25 Instance 0 : Successfully Started with no start checkup",
+				"Job 25 Instance 0 : exited with status: exit status 0",
+				"Job 25 Instance 0 : restart policy specifies do not restart",
+			})
+			os.Remove(testFile)
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestSocketActivation timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterSocketKeepsExistingFiles(t *testing.T) {
+	path := "test_scripts/SocketExisting.test"
+	if err := ioutil.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	socket := &JOB.Socket{Network: "unix", Address: path, Backlog: 1}
+	if err := socket.Listen(); err == nil {
+		socket.File.Close()
+		t.Errorf("Error: a file which is not a socket should not be replaced")
+	} else if contents, err := FileContains(path); err != nil || contents != "data" {
+		t.Errorf("Error: the existing file should be kept: %q %v", contents, err)
+	}
+	os.Remove(path)
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	if err := socket.Listen(); err == nil {
+		socket.File.Close()
+		t.Errorf("Error: a socket still listening should not be replaced")
+	} else if conn, err := net.Dial("unix", path); err != nil {
+		t.Errorf("Error: the listening socket should be kept: %s", err)
+	} else {
+		conn.Close()
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterWatchRestart(t *testing.T) {
+	dir := "test_scripts/Watch.test.d"
+	ch := make(chan error)
+	os.MkdirAll(dir+"/sub", 0755)
+	defer os.RemoveAll(dir)
+	s := PrepareSupervisor(t, "procfiles/Watch.yaml")
+	j, _ := s.Mgr.GetJob(26)
+	go func() {
+		s.StartJob(26, true)
+		pid := j.Instances[0].PID()
+		time.Sleep(time.Duration(100) * time.Millisecond)
+		os.WriteFile(dir+"/sub/config.swp", []byte("ignored"), 0644)
+		time.Sleep(time.Duration(500) * time.Millisecond)
+		if j.Instances[0].PID() != pid {
+			ch <- fmt.Errorf("excluded file should not restart the job")
+			return
+		}
+		os.WriteFile(dir+"/sub/config", []byte("changed"), 0644)
+		for n := 0; j.Instances[0].PID() == pid || !j.Instances[0].Running(); n++ {
+			if n > 50 {
+				ch <- fmt.Errorf("job was not restarted after file changed")
+				return
+			}
+			time.Sleep(time.Duration(100) * time.Millisecond)
+		}
+		s.StopJob(26)
+		j.Instances[0].WaitForIdle()
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 26 : watching test_scripts/Watch.test.d",
+				"Job 26 Instance 0 : Successfully Started with no start checkup",
+				"Job 26 : test_scripts/Watch.test.d/sub/config changed, restarting",
+				"Job 26 Instance 0 : Sending Signal interrupt",
+				"Job 26 Instance 0 : exited with status: signal: interrupt",
+				"Job 26 Instance 0 : stopped by user, not restarting",
+				"Job 26 Instance 0 : Successfully Started with no start checkup",
+				"Job 26 Instance 0 : Sending Signal interrupt",
+				"Job 26 Instance 0 : exited with status: signal: interrupt",
+				"Job 26 Instance 0 : stopped by user, not restarting",
+			})
+			if strings.Contains(logs, "config.swp") {
+				t.Errorf("Error: excluded file triggered the watch\n%s", logs)
+			}
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestWatchRestart timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterWatchdogRestart(t *testing.T) {
+	testFile := "test_scripts/Watchdog.test"
+	ch := make(chan error)
+	reason := ""
+	os.Remove(testFile)
+	defer os.Remove(testFile)
+	s := PrepareSupervisor(t, "procfiles/Watchdog.yaml")
+	j, _ := s.Mgr.GetJob(27)
+	go func() {
+		s.StartJob(27, true)
+		pid := j.Instances[0].PID()
+		for n := 0; j.Instances[0].PID() == pid || !j.Instances[0].Running(); n++ {
+			if n > 50 {
+				ch <- fmt.Errorf("job was not restarted after breaching its limit")
+				return
+			}
+			time.Sleep(time.Duration(100) * time.Millisecond)
+		}
+		reason = j.Instances[0].ExitReason
+		s.StopJob(27)
+		j.Instances[0].WaitForIdle()
+		if !strings.HasPrefix(reason, "open files limit exceeded:") {
+			ch <- fmt.Errorf("exit reason should record the breach, actually %q", reason)
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 27 Instance 0 : Successfully Started with no start checkup",
+				"Job 27 Instance 0 : " + reason + " , restarting",
+				"Job 27 Instance 0 : Sending Signal terminated",
+				"Job 27 Instance 0 : exited with status: signal: terminated",
+				"Job 27 Instance 0 : Successfully Started with no start checkup",
+				"Job 27 Instance 0 : Sending Signal terminated",
+				"Job 27 Instance 0 : exited with status: signal: terminated",
+				"Job 27 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestWatchdogRestart timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterWatchdogBreachDuringStart(t *testing.T) {
+	testFile := "test_scripts/WatchdogStart.test"
+	ch := make(chan error)
+	os.Remove(testFile)
+	defer os.Remove(testFile)
+	s := PrepareSupervisor(t, "procfiles/WatchdogStart.yaml")
+	j, _ := s.Mgr.GetJob(44)
+	go func() {
+		s.StartJob(44, false)
+		time.Sleep(time.Duration(100) * time.Millisecond)
+		j.Instances[0].WaitForIdle()
+		ch <- nil
+	}()
+	select {
+	case <-ch:
+		logs := Buf.String()
+		breach := regexp.MustCompile("open files limit exceeded:[^\n]*").FindString(logs)
+		LogsContain(t, logs, []string{
+			"Job 44 Instance 0 : " + breach,
+			"Job 44 Instance 0 : Sending Signal terminated",
+			"Job 44 Instance 0 : exited with status: signal: terminated",
+			"Job 44 Instance 0 : monitor failed, program exit:  1  with job status 2",
+			"Job 44 Instance 0 : Start failed, restarting",
+			"Job 44 Instance 0 : Successfully Started after 1 second(s)",
+			"Job 44 Instance 0 : exited with status: exit status 0",
+			"Job 44 Instance 0 : restart policy specifies do not restart",
+		})
+	case <-time.After(time.Duration(10) * time.Second):
+		s.StopJob(44)
+		j.Instances[0].WaitForIdle()
+		t.Errorf("TestWatchdogBreachDuringStart timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterMaxRuntime(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/MaxRuntime.yaml")
+	j, _ := s.Mgr.GetJob(28)
+	go func() {
+		s.StartJob(28, true)
+		pid := j.Instances[0].PID()
+		for n := 0; j.Instances[0].PID() == pid || !j.Instances[0].Running(); n++ {
+			if n > 30 {
+				ch <- fmt.Errorf("job was not restarted after exceeding maxRuntime")
+				return
+			}
+			time.Sleep(time.Duration(100) * time.Millisecond)
+		}
+		reason := j.Instances[0].ExitReason
+		s.StopJob(28)
+		j.Instances[0].WaitForIdle()
+		if reason != "timeout" {
+			ch <- fmt.Errorf("exit reason should be timeout, actually %q", reason)
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 28 Instance 0 : Successfully Started with no start checkup",
+				"Job 28 Instance 0 : exceeded maxRuntime of 500ms , stopping",
+				"Job 28 Instance 0 : Sending Signal interrupt",
+				"Job 28 Instance 0 : exited with status: signal: interrupt",
+				"Job 28 Instance 0 : Encountered unexpected exit code -1 , restarting",
+				"Job 28 Instance 0 : Successfully Started with no start checkup",
+				"Job 28 Instance 0 : Sending Signal interrupt",
+				"Job 28 Instance 0 : exited with status: signal: interrupt",
+				"Job 28 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestMaxRuntime timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterPauseResume(t *testing.T) {
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Pause.yaml")
+	j, _ := s.Mgr.GetJob(29)
+	instance := j.Instances[0]
+	go func() {
+		s.StartJob(29, false)
+		for !instance.Running() {
+			time.Sleep(time.Duration(10) * time.Millisecond)
+		}
+		if err := s.PauseJob(29, -1); err != nil {
+			ch <- err
+			return
+		}
+		for n := 0; ; n++ {
+			if stat, err := proc.ReadStat(instance.PID()); err != nil {
+				ch <- err
+				return
+			} else if stat.State == 'T' {
+				break
+			} else if n > 100 {
+				ch <- fmt.Errorf("paused process should be stopped, actually %c", stat.State)
+				return
+			}
+			time.Sleep(time.Duration(10) * time.Millisecond)
+		}
+		time.Sleep(time.Duration(1500) * time.Millisecond)
+		if status := instance.GetStatus(); status != "paused" {
+			ch <- fmt.Errorf("start check should wait while paused, status %s", status)
+			return
+		} else if err := s.ResumeJob(29, 0); err != nil {
+			ch <- err
+			return
+		}
+		for instance.GetStatus() != "running" {
+			time.Sleep(time.Duration(10) * time.Millisecond)
+		}
+		s.PauseJob(29, 0)
+		s.StopJob(29)
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 29 Instance 0 : paused",
+				"Job 29 Instance 0 : resumed",
+				"Job 29 Instance 0 : Successfully Started after 1 second(s)",
+				"Job 29 Instance 0 : paused",
+				"Job 29 Instance 0 : Sending Signal interrupt",
+				"Job 29 Instance 0 : resumed",
+				"Job 29 Instance 0 : exited with status: signal: interrupt",
+				"Job 29 Instance 0 : stopped by user, not restarting",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestPauseResume timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterSignalCommand(t *testing.T) {
+	testFile := "test_scripts/Signal.test"
+	sock := "test_scripts/Signal.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Signal.yaml")
+	j, _ := s.Mgr.GetJob(30)
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var out strings.Builder
+	go func() {
+		s.StartJob(30, true)
+		// give the script time to install its trap
+		time.Sleep(time.Duration(300) * time.Millisecond)
+		if err := UI.Control(sock, "signal bogus 30", nil, &out); err != nil {
+			ch <- err
+		} else if err := UI.Control(sock, "signal int 30 0", nil, &out); err != nil {
+			ch <- err
+		} else {
+			time.Sleep(time.Duration(1500) * time.Millisecond)
+			if !j.Instances[0].Running() {
+				ch <- fmt.Errorf("trapped signal should not stop the job")
+				return
+			}
+			s.StopJob(30)
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else if contents, err := FileContains(testFile); err != nil {
+			t.Errorf("Error: file error\n%s\nlogs:%s", err, logs)
+		} else if contents != "INT caught" {
+			t.Errorf("Error: incorrect string\n%s\nlogs:%s", contents, logs)
+		} else if out.String() != "Error: unknown signal SIGBOGUS\nSending SIGINT to 30\n" {
+			t.Errorf("Error: incorrect response\n%s", out.String())
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 30 Instance 0 : Successfully Started with no start checkup",
+				"Job 30 Instance 0 : Sending Signal interrupt",
+				"Job 30 Instance 0 : Sending Signal terminated",
+				"Job 30 Instance 0 : exited with status: signal: terminated",
+				"Job 30 Instance 0 : stopped by user, not restarting",
+			})
+			os.Remove(testFile)
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestSignalCommand timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterAttach(t *testing.T) {
+	testFile := "test_scripts/Attach.test"
+	sock := "test_scripts/Attach.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Attach.yaml")
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		s.StartJob(31, true)
+		go UI.Control(sock, "attach 31", inR, outW)
+		lines := bufio.NewScanner(outR)
+		expect := func(line string) bool {
+			if !lines.Scan() || lines.Text() != line {
+				ch <- fmt.Errorf("expected %q, received %q", line, lines.Text())
+				return false
+			}
+			return true
+		}
+		if !expect("Attached to Job 31 Instance 0 detach with Ctrl-] then Enter") {
+			return
+		}
+		fmt.Fprintln(inW, "hello")
+		if !expect("hello") {
+			return
+		}
+		fmt.Fprintln(inW, UI.DETACHKEY)
+		if !expect("Detached from Job 31 Instance 0") {
+			return
+		}
+		s.StopJob(31)
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else if contents, err := FileContains(testFile); err != nil {
+			t.Errorf("Error: file error\n%s\nlogs:%s", err, logs)
+		} else if contents != "hello\n" {
+			t.Errorf("Error: incorrect string\n%q\nlogs:%s", contents, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 31 Instance 0 : Successfully Started with no start checkup",
+				"Job 31 Instance 0 : Sending Signal terminated",
+				"Job 31 Instance 0 : exited with status: signal: terminated",
+				"Job 31 Instance 0 : stopped by user, not restarting",
+			})
+			os.Remove(testFile)
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestAttach timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterTTY(t *testing.T) {
+	testFile := "test_scripts/TTY.test"
+	expected := "stdin is a terminal\r\nhas a controlling terminal\r\n30 100\r\n"
+	ch := make(chan string)
+	s := PrepareSupervisor(t, "procfiles/TTY.yaml")
+	go func() {
+		s.StartJob(32, false)
+		contents := ""
+		done := "restart policy specifies do not restart"
+		for n := 0; n < 50; n++ {
+			time.Sleep(time.Duration(100) * time.Millisecond)
+			contents, _ = FileContains(testFile)
+			if contents == expected && strings.Contains(Buf.String(), done) {
+				break
+			}
+		}
+		ch <- contents
+	}()
+	select {
+	case contents := <-ch:
+		logs := Buf.String()
+		if contents != expected {
+			t.Errorf("Error: incorrect string\n%q\nlogs:%s", contents, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 32 Instance 0 : Successfully Started with no start checkup",
+				"Job 32 Instance 0 : exited with status: exit status 0",
+				"Job 32 Instance 0 : restart policy specifies do not restart",
+			})
+			os.Remove(testFile)
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestTTY timed out, logs:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterLogRotation(t *testing.T) {
+	ch := make(chan struct{})
+	s := PrepareSupervisor(t, "procfiles/LogRotation.yaml")
+	go func() {
+		s.StartJob(33, false)
+		for n := 0; n < 50; n++ {
+			time.Sleep(time.Duration(100) * time.Millisecond)
+			logs := Buf.String()
+			if strings.Count(logs, "restart policy specifies do not restart") == 2 {
+				break
+			}
+		}
+		ch <- struct{}{}
+	}()
+	select {
+	case <-ch:
+		logs := Buf.String()
+		for n := 0; n < 2; n++ {
+			testFile := fmt.Sprintf("test_scripts/LogRotation.%d.test", n)
+			if contents, err := FileContains(testFile); err != nil {
+				t.Errorf("Error: file error\n%s\nlogs:%s", err, logs)
+			} else if contents != "third\n" {
+				t.Errorf("Error: incorrect string\n%q\nlogs:%s", contents, logs)
+			} else if contents, err := FileContains(testFile + ".1"); err != nil {
+				t.Errorf("Error: backup error\n%s\nlogs:%s", err, logs)
+			} else if contents != "second\n" {
+				t.Errorf("Error: incorrect backup\n%q\nlogs:%s", contents, logs)
+			}
+			os.Remove(testFile)
+			os.Remove(testFile + ".1")
+		}
+		LogsContain(t, logs, []string{
+			"Job 33 Instance 0 : Successfully Started with no start checkup",
+			"Job 33 Instance 0 : exited with status: exit status 0",
+			"Job 33 Instance 0 : restart policy specifies do not restart",
+			"Job 33 Instance 1 : Successfully Started with no start checkup",
+			"Job 33 Instance 1 : exited with status: exit status 0",
+			"Job 33 Instance 1 : restart policy specifies do not restart",
+		})
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestLogRotation timed out, logs:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterTail(t *testing.T) {
+	testFile := "test_scripts/Tail.test"
+	sock := "test_scripts/Tail.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Tail.yaml")
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		s.StartJob(34, false)
+		time.Sleep(time.Duration(300) * time.Millisecond)
+		var last bytes.Buffer
+		if err := UI.Control(sock, "tail -n 2 34", nil, &last); err != nil {
+			ch <- err
+			return
+		} else if last.String() != "four\nfive\n" {
+			ch <- fmt.Errorf("expected last lines, received %q", last.String())
+			return
+		}
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+		done := make(chan error)
+		go func() {
+			done <- UI.Control(sock, "tail -f -n 1 34", inR, outW)
+		}()
+		lines := bufio.NewScanner(outR)
+		for _, line := range []string{"five", "six"} {
+			if !lines.Scan() || lines.Text() != line {
+				ch <- fmt.Errorf("expected %q, received %q", line, lines.Text())
+				return
+			}
+		}
+		inW.Close()
+		if err := <-done; err != nil {
+			ch <- err
+			return
+		}
+		s.StopJob(34)
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 34 Instance 0 : Successfully Started with no start checkup",
+				"Job 34 Instance 0 : Sending Signal terminated",
+				"Job 34 Instance 0 : exited with status: signal: terminated",
+				"Job 34 Instance 0 : stopped by user, not restarting",
+			})
+			os.Remove(testFile)
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestTail timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterLastLog(t *testing.T) {
+	sock := "test_scripts/LastLog.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/LastLog.yaml")
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		j, _ := s.Mgr.GetJob(35)
+		s.StartJob(35, false)
+		<-j.Instances[0].FinishedCh
+		var last bytes.Buffer
+		expected := "line 4\nline 5\nline 6\nfailed to connect\n"
+		if err := UI.Control(sock, "lastlog 35 0", nil, &last); err != nil {
+			ch <- err
+		} else if last.String() != expected {
+			ch <- fmt.Errorf("expected recent output, received %q", last.String())
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 35 Instance 0 : Successfully Started with no start checkup",
+				"Job 35 Instance 0 : exited with status: exit status 3",
+				`Job 35 Instance 0 : last output: "line 4\nline 5\nline 6\nfailed to connect"`,
+				"Job 35 Instance 0 : restart policy specifies do not restart",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestLastLog timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	syslog, err := LOG.NewSyslog("udp://"+conn.LocalAddr().String(), "local3", "", "rfc5424")
+	if err != nil {
+		t.Fatal(err)
+	}
+	LOG.SetSyslog(syslog)
+	defer LOG.SetSyslog(nil)
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Syslog.yaml")
+	go func() {
+		j, _ := s.Mgr.GetJob(36)
+		s.StartJob(36, false)
+		buf := make([]byte, 1024)
+		for _, expected := range []string{
+			`^<158>1 \S+ \S+ syslog.sh - stdout - serving$`,
+			`^<156>1 \S+ \S+ syslog.sh - stderr - disk almost full$`,
+		} {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(5) * time.Second))
+			if n, _, err := conn.ReadFrom(buf); err != nil {
+				ch <- err
+				return
+			} else if !regexp.MustCompile(expected).Match(buf[:n]) {
+				ch <- fmt.Errorf("expected message matching %s, received %q", expected, buf[:n])
+				return
+			}
+		}
+		j.Instances[0].WaitForIdle()
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, Buf.String())
+		} else {
+			LogsContain(t, Buf.String(), []string{
+				"Job 36 Instance 0 : Successfully Started with no start checkup",
+				"Job 36 Instance 0 : exited with status: exit status 0",
+				"Job 36 Instance 0 : restart policy specifies do not restart",
+			})
+		}
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestSyslog timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterReopenLogs(t *testing.T) {
+	testFile := "test_scripts/ReopenLogs.test"
+	sock := "test_scripts/ReopenLogs.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/ReopenLogs.yaml")
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		j, _ := s.Mgr.GetJob(37)
+		s.StartJob(37, false)
+		time.Sleep(time.Duration(300) * time.Millisecond)
+		pid := j.Instances[0].PID()
+		os.Rename(testFile, testFile+".1")
+		var out bytes.Buffer
+		if err := UI.Control(sock, "reopen-logs", nil, &out); err != nil {
+			ch <- err
+			return
+		} else if out.String() != "Reopened log files\n" {
+			ch <- fmt.Errorf("expected logs reopened, received %q", out.String())
+			return
+		}
+		time.Sleep(time.Duration(300) * time.Millisecond)
+		if j.Instances[0].PID() != pid {
+			ch <- fmt.Errorf("process should run undisturbed")
+			return
+		}
+		s.StopJob(37)
+		j.Instances[0].WaitForIdle()
+		rotated, _ := FileContains(testFile + ".1")
+		reopened, _ := FileContains(testFile)
+		lines := strings.Fields(strings.ReplaceAll(rotated+reopened, "line", ""))
+		for n, line := range lines {
+			if line != strconv.Itoa(n+1) {
+				ch <- fmt.Errorf("output lost when reopening:\n%s---\n%s", rotated, reopened)
+				return
+			}
+		}
+		if rotated == "" || reopened == "" {
+			ch <- fmt.Errorf("output should be split across files:\n%s---\n%s", rotated, reopened)
+			return
+		}
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 37 Instance 0 : Successfully Started with no start checkup",
+				"Supervisor: reopened log files",
+				"Job 37 Instance 0 : Sending Signal terminated",
+				"Job 37 Instance 0 : exited with status: signal: terminated",
+				"Job 37 Instance 0 : stopped by user, not restarting",
+			})
+		}
+		os.Remove(testFile)
+		os.Remove(testFile + ".1")
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestReopenLogs timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterOutputFraming(t *testing.T) {
+	testFile := "test_scripts/OutputFraming.test"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/OutputFraming.yaml")
+	go func() {
+		j, _ := s.Mgr.GetJob(38)
+		s.StartJob(38, false)
+		time.Sleep(time.Duration(100) * time.Millisecond)
+		pid := j.Instances[0].PID()
+		j.Instances[0].WaitForIdle()
+		content, err := FileContains(testFile)
+		if err != nil {
+			ch <- err
+			return
+		}
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		expected := []string{
+			fmt.Sprintf("38.0 stdout[%d] ready", pid),
+			fmt.Sprintf("38.0 stderr[%d] disk almost full", pid),
+			fmt.Sprintf("38.0 stdout[%d] shutting down", pid),
+		}
+		stamp := regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* `)
+		if len(lines) != len(expected) {
+			ch <- fmt.Errorf("expected %d framed lines, received:\n%s", len(expected), content)
+			return
+		}
+		for n, line := range lines {
+			if !stamp.MatchString(line) || stamp.ReplaceAllString(line, "") != expected[n] {
+				ch <- fmt.Errorf("expected %q framed, received:\n%s", expected[n], content)
+				return
+			}
+		}
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 38 Instance 0 : Successfully Started with no start checkup",
+				"Job 38 Instance 0 : exited with status: exit status 0",
+				"Job 38 Instance 0 : restart policy specifies do not restart",
+			})
+		}
+		os.Remove(testFile)
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestOutputFraming timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+func TestTaskMasterOutputLimits(t *testing.T) {
+	testFile := "test_scripts/OutputLimits.test"
+	sock := "test_scripts/OutputLimits.sock"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/OutputLimits.yaml")
+	listener, err := UI.ServeControl(s, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		j, _ := s.Mgr.GetJob(39)
+		s.StartJob(39, false)
+		ch <- checkOutputLimits(testFile, sock)
+		s.StopJob(39)
+		j.Instances[0].WaitForIdle()
+		ch <- nil
+	}()
+	select {
+	case err := <-ch:
+		<-ch
+		logs := Buf.String()
+		if err != nil {
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 39 Instance 0 : Successfully Started with no start checkup",
+				"Job 39 Instance 0 : Sending Signal terminated",
+				"Job 39 Instance 0 : exited with status: signal: terminated",
+				"Job 39 Instance 0 : stopped by user, not restarting",
+			})
+		}
+		os.Remove(testFile)
+	case <-time.After(time.Duration(10) * time.Second):
+		t.Errorf("TestOutputLimits timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
+
+/*
+ * checkOutputLimits waits for the output of the flood job & checks that
+ * lines past its limits were cut or suppressed & counted in its status
+ */
+func checkOutputLimits(testFile, sock string) error {
+	content := ""
+	for n := 0; !strings.HasSuffix(content, "done\n") && n < 30; n++ {
+		time.Sleep(time.Duration(100) * time.Millisecond)
+		content, _ = FileContains(testFile)
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if lines[0] != "0123456789abcdef [taskmaster: 10 bytes truncated]" {
+		return fmt.Errorf("expected long line cut, received:\n%s", content)
+	}
+	marker := regexp.MustCompile(`^\[taskmaster: (\d+) lines? suppressed\]$`)
+	kept, suppressed := 0, 0
+	for _, line := range lines[1:] {
+		if m := marker.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			suppressed += n
+		} else if strings.HasPrefix(line, "line ") {
+			kept++
+		}
+	}
+	if kept < 4 || suppressed == 0 || kept+suppressed != 100 {
+		return fmt.Errorf("expected lines suppressed past the burst, received:\n%s", content)
+	}
+	var ps bytes.Buffer
+	expected := fmt.Sprintf("%-12s", fmt.Sprintf("%d (1 cut)", suppressed))
+	if err := UI.Control(sock, "ps", nil, &ps); err != nil {
+		return err
+	} else if !strings.Contains(ps.String(), expected) {
+		return fmt.Errorf("expected %q in status, received:\n%s", expected, ps.String())
+	}
+	return nil
+}
+
+func TestTaskMasterReapOrphans(t *testing.T) {
+	pidFile := "test_scripts/Orphan.pid"
+	ch := make(chan error)
+	s := PrepareSupervisor(t, "procfiles/Orphan.yaml")
+	if err := EnableSubreaper(); err != nil {
+		t.Skip("unable to become a child subreaper:", err)
+	}
+	// scanned by hand rather than by Subreap, so that orphans of later tests
+	// are not logged
+	s.Reaper = NewReaper()
+	orphan := 0
+	go func() {
+		s.StartJob(40, false)
+		for n := 0; n < 50; n++ {
+			time.Sleep(time.Duration(100) * time.Millisecond)
+			content, _ := FileContains(pidFile)
+			if orphan, _ = strconv.Atoi(strings.TrimSpace(content)); orphan == 0 {
+				continue
+			}
+			s.Reap()
+			if _, ok := s.Reaper.Orphans[orphan]; ok {
+				break
+			}
+		}
+		if label := s.Reaper.Orphans[orphan]; label != "Job 40 Instance 0" {
+			ch <- fmt.Errorf("orphan %d should be attributed to its job, not %q", orphan, label)
+			return
+		}
+		// children taskmaster waits on itself are left alone once they exit
+		cmd := exec.Command("true")
+		if err := cmd.Start(); err != nil {
+			ch <- err
+			return
+		}
+		time.Sleep(time.Duration(100) * time.Millisecond)
+		s.Reap()
+		s.Reap()
+		s.Reap()
+		if err := cmd.Wait(); err != nil {
+			ch <- fmt.Errorf("taskmaster's own child should not be reaped: %s", err)
+			return
+		}
+		s.Shutdown()
+		if len(s.Reaper.Orphans) != 0 {
+			ch <- fmt.Errorf("orphan %d should be reaped on shutdown", orphan)
+		} else if err := syscall.Kill(orphan, 0); err == nil {
+			ch <- fmt.Errorf("orphan %d should no longer exist", orphan)
+		} else {
+			ch <- nil
+		}
+	}()
+	select {
+	case err := <-ch:
+		logs := Buf.String()
+		if err != nil {
+			syscall.Kill(orphan, syscall.SIGKILL)
+			t.Errorf("Err not nil:\n%s\n%s", err, logs)
+		} else {
+			LogsContain(t, logs, []string{
+				"Job 40 Instance 0 : Successfully Started with no start checkup",
+				fmt.Sprintf("Supervisor: adopted orphan %d sleep from Job 40 Instance 0", orphan),
+				"Job 40 Instance 0 : Sending Signal terminated",
+				"Job 40 Instance 0 : exited with status: signal: terminated",
+				"Job 40 Instance 0 : stopped by user, not restarting",
+				fmt.Sprintf("Supervisor: sending terminated to orphan %d from Job 40 Instance 0", orphan),
+				fmt.Sprintf("Supervisor: reaped orphan %d sleep from Job 40 Instance 0 with signal: terminated", orphan),
+			})
+		}
+		os.Remove(pidFile)
+	case <-time.After(time.Duration(15) * time.Second):
+		t.Errorf("TestReapOrphans timed out, log:\n%s", Buf.String())
+	}
+	Buf.Reset()
+}
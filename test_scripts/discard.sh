@@ -0,0 +1,4 @@
+#!/bin/bash
+echo discarded
+echo discarded >&2
+exec sleep 10
@@ -0,0 +1,8 @@
+#!/bin/bash
+echo "0123456789abcdef0123456789"
+for n in $(seq 1 100); do
+    echo "line $n"
+done
+sleep 1
+echo "done"
+exec sleep 10
@@ -0,0 +1,6 @@
+#!/bin/bash
+echo "ready"
+sleep 0.1
+echo "disk almost full" >&2
+sleep 0.1
+printf "shutting down"
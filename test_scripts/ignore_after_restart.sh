@@ -0,0 +1,10 @@
+#!/bin/bash
+# exits on its first run, then ignores its stop signal once restarted
+FILE=test_scripts/ignore_after_restart_tmp
+if [ ! -f "$FILE" ]; then
+    touch $FILE
+    exit 1
+fi
+trap '' TERM INT
+rm $FILE
+exec sleep 10
@@ -0,0 +1,8 @@
+#!/bin/bash
+
+trap '' TERM INT
+while :
+do
+sleep 1
+done
+exit 0
@@ -0,0 +1,7 @@
+#!/bin/bash
+for i in 1 2 3 4 5 6; do
+    echo "line $i"
+done
+sleep 0.1
+echo "failed to connect" >&2
+exit 3
@@ -0,0 +1,8 @@
+#!/bin/bash
+FILE=test_scripts/taskmaster_oneshot_tmp
+if [ ! -f "$FILE" ]; then
+    touch $FILE
+    exit 3
+fi
+rm $FILE
+exit 0
@@ -0,0 +1,14 @@
+#!/bin/bash
+
+if [ ! -e test_scripts/Watchdog.test ]; then
+    touch test_scripts/Watchdog.test
+    sleep 0.5
+    for fd in $(seq 10 30); do
+        eval "exec $fd</dev/null"
+    done
+fi
+while :
+do
+sleep 1
+done
+exit 0
@@ -0,0 +1,4 @@
+#!/bin/bash
+# leave a grandchild behind, reparented to taskmaster once the subshell exits
+(sleep 30 >/dev/null 2>&1 & echo $! > test_scripts/Orphan.pid)
+exec sleep 30
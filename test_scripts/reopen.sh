@@ -0,0 +1,7 @@
+#!/bin/bash
+n=0
+while true; do
+    n=$((n + 1))
+    echo "line $n"
+    sleep 0.1
+done
@@ -0,0 +1,6 @@
+#!/bin/bash
+if [ "$LISTEN_PID" = "$$" ]; then
+    PID=pid
+fi
+echo -n $LISTEN_FDS $LISTEN_FDNAMES $PID $(readlink /proc/$$/fd/3 | cut -d: -f1)
+exit 0
@@ -0,0 +1,4 @@
+#!/bin/bash
+echo "serving"
+sleep 0.1
+echo "disk almost full" >&2
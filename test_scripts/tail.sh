@@ -0,0 +1,5 @@
+#!/bin/bash
+printf "one\ntwo\nthree\nfour\nfive\n"
+sleep 1
+echo "six"
+exec sleep 30
@@ -0,0 +1,5 @@
+#!/bin/bash
+tty -s && echo "stdin is a terminal"
+: < /dev/tty && echo "has a controlling terminal"
+stty size
+exit 0
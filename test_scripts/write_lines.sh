@@ -0,0 +1,7 @@
+#!/bin/bash
+echo "first"
+sleep 0.1
+echo "second"
+sleep 0.1
+echo "third"
+exit 0
@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	JOB "github.com/Travmatth/taskmaster/job"
+	. "github.com/Travmatth/taskmaster/log"
+	S "github.com/Travmatth/taskmaster/supervisor"
+)
+
+/*
+ * STATUSPREFIX starts the line ending the response to a command with an
+ * exit status, such as start --wait, the NUL keeping it apart from output
+ */
+const STATUSPREFIX = "\x00status "
+
+/*
+ * ExitError is returned by Control when the command ended with a non zero
+ * exit status, for the client to exit with
+ */
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+/*
+ * ServeControl listens on a unix socket at path, running the commands sent
+ * on each connection as if they were typed in the ui and writing back the
+ * responses, until the connection closes or sends exit. It refuses to
+ * replace the socket of a taskmaster still listening at path
+ */
+func ServeControl(supervisor *S.Supervisor, path string) (net.Listener, error) {
+	if err := JOB.RemoveStaleSocket(path); err != nil {
+		return nil, err
+	}
+	// the socket is created without access for other users, rather than
+	// restricted after they may have connected
+	umask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				Log.Info("Control: stopped accepting connections:", err)
+				return
+			}
+			go func() {
+				defer conn.Close()
+				session := NewSession(supervisor, conn)
+				session.StartUI()
+				if session.status != nil {
+					fmt.Fprintf(conn, "%s%d\n", STATUSPREFIX, *session.status)
+				}
+			}()
+		}
+	}()
+	return listener, nil
+}
+
+/*
+ * Control sends a command to the control socket at path, followed by in if
+ * it is not nil, such as the input of an attach command, copying the
+ * response to out until taskmaster closes the connection. An ExitError is
+ * returned if the command ended with a non zero exit status
+ */
+func Control(path, command string, in io.Reader, out io.Writer) error {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = fmt.Fprintln(conn, command); err != nil {
+		return err
+	}
+	if in == nil {
+		conn.(*net.UnixConn).CloseWrite()
+	} else {
+		go func() {
+			io.Copy(conn, in)
+			conn.(*net.UnixConn).CloseWrite()
+		}()
+	}
+	status := &statusWriter{out: out, lineStart: true}
+	if _, err = io.Copy(status, conn); err != nil {
+		return err
+	}
+	status.flush()
+	if status.found && status.code != 0 {
+		return &ExitError{Code: status.code}
+	}
+	return nil
+}
+
+/*
+ * statusWriter copies a response to out, removing the line giving its exit
+ * status. A line starting with NUL is held back only while it may still be
+ * that line, so that output such as that of attach is not delayed
+ */
+type statusWriter struct {
+	out       io.Writer
+	held      []byte
+	lineStart bool
+	found     bool
+	code      int
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	pass := []byte{}
+	for _, c := range p {
+		if len(w.held) != 0 || c == 0 && w.lineStart {
+			w.held = append(w.held, c)
+			if w.status() {
+				w.held = nil
+			} else if !w.possible() {
+				pass, w.held = append(pass, w.held...), nil
+			}
+		} else {
+			pass = append(pass, c)
+		}
+		w.lineStart = c == '\n'
+	}
+	if _, err := w.out.Write(pass); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+/*
+ * possible reports whether the bytes held back may yet form the status line
+ */
+func (w *statusWriter) possible() bool {
+	held := string(w.held)
+	if len(held) <= len(STATUSPREFIX) {
+		return strings.HasPrefix(STATUSPREFIX, held)
+	}
+	digits := strings.TrimPrefix(held[len(STATUSPREFIX):], "-")
+	return strings.HasPrefix(held, STATUSPREFIX) &&
+		strings.Trim(digits, "0123456789") == ""
+}
+
+/*
+ * status parses the bytes held back once they form the status line
+ */
+func (w *statusWriter) status() bool {
+	held := string(w.held)
+	if !strings.HasPrefix(held, STATUSPREFIX) || !strings.HasSuffix(held, "\n") {
+		return false
+	}
+	code, err := strconv.Atoi(strings.TrimSuffix(held[len(STATUSPREFIX):], "\n"))
+	if err != nil {
+		return false
+	}
+	w.found, w.code = true, code
+	return true
+}
+
+/*
+ * flush writes out bytes held back by a response ending mid way through
+ * what looked like the status line
+ */
+func (w *statusWriter) flush() {
+	if len(w.held) != 0 {
+		w.out.Write(w.held)
+		w.held = nil
+	}
+}
@@ -9,19 +9,33 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	INST "github.com/Travmatth/taskmaster/instance"
 	JOB "github.com/Travmatth/taskmaster/job"
+	"github.com/Travmatth/taskmaster/output"
+	"github.com/Travmatth/taskmaster/pty"
 	SIG "github.com/Travmatth/taskmaster/signals"
 	S "github.com/Travmatth/taskmaster/supervisor"
 )
 
+/*
+ * DETACHKEY is the key, Ctrl-], which followed by Enter detaches from an
+ * attached instance
+ */
+const DETACHKEY = "\x1d"
+
 /*
  * Frontend models the ui shown to the user
  */
 type Frontend struct {
 	supervisor *S.Supervisor
 	scanner    *bufio.Scanner
+	out        io.Writer
+	prompt     string
+	status     *int
 }
 
 /*
@@ -31,6 +45,21 @@ func NewFrontend(supervisor *S.Supervisor) (f *Frontend) {
 	f = &Frontend{
 		supervisor: supervisor,
 		scanner:    bufio.NewScanner(os.Stdin),
+		out:        os.Stdout,
+		prompt:     "> ",
+	}
+	return
+}
+
+/*
+ * NewSession creates a Frontend reading commands from and writing responses
+ * to a control socket connection, without prompting
+ */
+func NewSession(supervisor *S.Supervisor, conn io.ReadWriter) (f *Frontend) {
+	f = &Frontend{
+		supervisor: supervisor,
+		scanner:    bufio.NewScanner(conn),
+		out:        conn,
 	}
 	return
 }
@@ -39,7 +68,7 @@ func NewFrontend(supervisor *S.Supervisor) (f *Frontend) {
  * StartUI is the read-eval loop
  */
 func (f *Frontend) StartUI() {
-	fmt.Print("> ")
+	fmt.Fprint(f.out, f.prompt)
 UILoop:
 	for f.scanner.Scan() {
 		input := strings.ToLower(f.scanner.Text())
@@ -47,9 +76,9 @@ UILoop:
 		if end == true {
 			break UILoop
 		}
-		fmt.Print("> ")
+		fmt.Fprint(f.out, f.prompt)
 	}
-	if err := f.scanner.Err(); err != nil && err != io.EOF {
+	if err := f.scanner.Err(); err != nil && err != io.EOF && f.prompt != "" {
 		fmt.Fprintln(os.Stderr, err)
 		f.supervisor.SigCh <- SIG.Signals["SIGTERM"]
 	}
@@ -62,38 +91,94 @@ UILoop:
 func (f *Frontend) DecideCommand(input string) bool {
 	switch {
 	case input == "exit":
-		fmt.Println("Exiting TaskMaster")
+		fmt.Fprintln(f.out, "Exiting TaskMaster")
 		f.supervisor.SigCh <- SIG.Signals["SIGTERM"]
 		return true
 	case input == "reload":
-		fmt.Println("Reloading TaskMaster config")
+		fmt.Fprintln(f.out, "Reloading TaskMaster config")
 		f.supervisor.SigCh <- SIG.Signals["SIGHUP"]
+	case input == "upgrade":
+		fmt.Fprintln(f.out, "Upgrading TaskMaster")
+		f.supervisor.SigCh <- syscall.SIGUSR2
+	case strings.HasPrefix(input, "loglevel"):
+		spec := strings.TrimSpace(strings.TrimPrefix(input, "loglevel"))
+		if levels, err := f.supervisor.SetLogLevels(spec); err != nil {
+			fmt.Fprintln(f.out, "Error:", err)
+		} else {
+			fmt.Fprintln(f.out, "Log level:", levels)
+		}
+	case input == "reopen-logs":
+		if err := f.supervisor.ReopenLogs(); err != nil {
+			fmt.Fprintln(f.out, "Error:", err)
+		} else {
+			fmt.Fprintln(f.out, "Reopened log files")
+		}
 	case input == "logs":
 		f.PrintLogs()
 	case input == "clear":
 		command := exec.Command("clear")
-		command.Stdout = os.Stdout
+		command.Stdout = f.out
 		command.Run()
 	case input == "startall" || input == "start all":
-		fmt.Println("Starting all jobs")
+		fmt.Fprintln(f.out, "Starting all jobs")
 		f.supervisor.StartAllJobs(false)
 	case input == "stopall" || input == "stop all":
-		fmt.Println("Stopping all jobs")
+		fmt.Fprintln(f.out, "Stopping all jobs")
 		f.supervisor.StopAllJobs(false)
+	case strings.HasPrefix(input, "start") && strings.HasSuffix(input, "--wait"):
+		f.WithId(strings.TrimSuffix(input, "--wait"), func(id int) {
+			fmt.Fprintln(f.out, "Starting", id, "and waiting for it to finish")
+			f.supervisor.StartJob(id, true)
+			code, _ := f.supervisor.WaitJob(id)
+			fmt.Fprintln(f.out, "Job", id, "finished with exit code", code)
+			f.status = &code
+		})
 	case strings.HasPrefix(input, "start"):
 		f.WithId(input, func(id int) {
-			fmt.Println("Starting", id)
+			fmt.Fprintln(f.out, "Starting", id)
 			f.supervisor.StartJob(id, false)
 		})
 	case strings.HasPrefix(input, "stop"):
 		f.WithId(input, func(id int) {
-			fmt.Println("Stopping", id)
+			fmt.Fprintln(f.out, "Stopping", id)
 			f.supervisor.StopJob(id)
 		})
+	case strings.HasPrefix(input, "pause"):
+		f.WithInstance(input, func(id, instance int) {
+			fmt.Fprintln(f.out, "Pausing", id)
+			if err := f.supervisor.PauseJob(id, instance); err != nil {
+				fmt.Fprintln(f.out, err)
+			}
+		})
+	case strings.HasPrefix(input, "resume"):
+		f.WithInstance(input, func(id, instance int) {
+			fmt.Fprintln(f.out, "Resuming", id)
+			if err := f.supervisor.ResumeJob(id, instance); err != nil {
+				fmt.Fprintln(f.out, err)
+			}
+		})
+	case strings.HasPrefix(input, "attach"):
+		f.WithInstance(input, f.Attach)
+		// a control socket connection ends along with its attach session
+		return f.prompt == ""
+	case strings.HasPrefix(input, "signal"):
+		f.SignalJob(input)
+	case strings.HasPrefix(input, "resize"):
+		f.ResizeJob(input)
+	case strings.HasPrefix(input, "lastlog"):
+		f.WithInstance(input, f.LastLog)
+	case strings.HasPrefix(input, "tail"):
+		// a control socket connection ends along with the output followed
+		return f.Tail(input) && f.prompt == ""
+	case strings.HasPrefix(input, "history"):
+		f.WithId(input, func(id int) {
+			fmt.Fprint(f.out, f.FormatHistory(id))
+		})
 	case strings.HasPrefix(input, "ps"):
-		format := "%-12s%-12s%-12s%-12s\n"
-		fmt.Printf(format, "ID", "Instance", "PID", "Status")
-		fmt.Print(f.FormatJobs())
+		format := "%-12s%-12s%-12s%-12s%-18s%-12s%s\n"
+		fmt.Fprintf(f.out, format, "ID", "Instance", "PID", "Status",
+			"Next Run", "Suppressed", "Last Exit")
+		fmt.Fprint(f.out, f.FormatJobs())
 	case strings.HasPrefix(input, "help"):
 		f.PrintHelp()
 	default:
@@ -111,6 +196,247 @@ func (f *Frontend) WithId(input string, funcWithId func(id int)) {
 	}
 }
 
+/*
+ * WithInstance call a function with a valid id and the instance given after
+ * it, or -1 if no instance was given
+ */
+func (f *Frontend) WithInstance(input string, funcWithInstance func(id, instance int)) {
+	words := strings.Fields(input)
+	instance := -1
+	if len(words) == 3 {
+		n, err := strconv.Atoi(words[2])
+		if err != nil || n < 0 {
+			fmt.Fprintln(f.out, "Error: Please enter a valid instance")
+			return
+		}
+		instance = n
+		input = strings.Join(words[:2], " ")
+	}
+	f.WithId(input, func(id int) {
+		funcWithInstance(id, instance)
+	})
+}
+
+/*
+ * GetInstance returns the given instance of a job, the first if instance is
+ * -1, printing an error & returning nil if there is none
+ */
+func (f *Frontend) GetInstance(id, instance int) *INST.Instance {
+	job, err := f.supervisor.GetJob(id)
+	if instance == -1 {
+		instance = 0
+	}
+	if err != nil {
+		fmt.Fprintln(f.out, err)
+		return nil
+	} else if instance >= len(job.Instances) {
+		fmt.Fprintln(f.out, "Error:", job, "has no instance", instance)
+		return nil
+	}
+	return job.Instances[instance]
+}
+
+/*
+ * Attach connects the ui to the input & output of an interactive instance,
+ * the first if none is given, until the detach key is entered
+ */
+func (f *Frontend) Attach(id, instance int) {
+	inst := f.GetInstance(id, instance)
+	if inst == nil {
+		return
+	}
+	if !inst.Interactive {
+		fmt.Fprintln(f.out, "Error:", inst, "is not interactive")
+		return
+	}
+	fmt.Fprintln(f.out, "Attached to", inst, "detach with Ctrl-] then Enter")
+	detach := inst.AttachOutput(f.out)
+	defer detach()
+	for f.scanner.Scan() {
+		line := f.scanner.Text()
+		if strings.Contains(line, DETACHKEY) {
+			break
+		} else if err := inst.WriteInput([]byte(line + "\n")); err != nil {
+			fmt.Fprintln(f.out, "Detached from", inst, ":", err)
+			return
+		}
+	}
+	fmt.Fprintln(f.out, "Detached from", inst)
+}
+
+/*
+ * SignalJob parses `signal <SIGNAME> [id] [instance] [--group]`, sending the
+ * signal to the job's instances or their process groups
+ */
+func (f *Frontend) SignalJob(input string) {
+	words := strings.Fields(input)
+	group := len(words) > 0 && words[len(words)-1] == "--group"
+	if group {
+		words = words[:len(words)-1]
+	}
+	if len(words) < 2 {
+		fmt.Fprintln(f.out, "Error: Please enter a signal name")
+		return
+	}
+	sig, err := SIG.Parse(words[1])
+	if err != nil {
+		fmt.Fprintln(f.out, "Error:", err)
+		return
+	}
+	input = strings.Join(append(words[:1], words[2:]...), " ")
+	f.WithInstance(input, func(id, instance int) {
+		fmt.Fprintln(f.out, "Sending", SIG.Name(sig), "to", id)
+		err := f.supervisor.SignalJob(id, instance, sig, group)
+		if err != nil {
+			fmt.Fprintln(f.out, err)
+		}
+	})
+}
+
+/*
+ * ResizeJob parses `resize <cols>x<rows> [id] [instance]`, setting the
+ * terminal window size of the job's instances
+ */
+func (f *Frontend) ResizeJob(input string) {
+	words := strings.Fields(input)
+	if len(words) < 2 {
+		fmt.Fprintln(f.out, "Error: Please enter a size as <cols>x<rows>")
+		return
+	}
+	size, err := pty.ParseSize(words[1])
+	if err != nil {
+		fmt.Fprintln(f.out, "Error:", err)
+		return
+	}
+	input = strings.Join(append(words[:1], words[2:]...), " ")
+	f.WithInstance(input, func(id, instance int) {
+		fmt.Fprintln(f.out, "Resizing", id, "to", size)
+		if err := f.supervisor.ResizeJob(id, instance, size); err != nil {
+			fmt.Fprintln(f.out, err)
+		}
+	})
+}
+
+/*
+ * Tail parses `tail [-f] [-n N] <id> [instance] [stdout|stderr]`, printing
+ * the last lines written to the file an instance's output is redirected to,
+ * then following its output if -f is given until interrupted, or until the
+ * control socket connection closes. Returns whether output was followed
+ */
+func (f *Frontend) Tail(input string) bool {
+	follow, lines, stderr := false, 10, false
+	args := []string{"tail"}
+	words := strings.Fields(input)[1:]
+	for n := 0; n < len(words); n++ {
+		switch words[n] {
+		case "-f":
+			follow = true
+		case "-n":
+			if n++; n == len(words) {
+				fmt.Fprintln(f.out, "Error: Please enter a number of lines")
+				return false
+			} else if val, err := strconv.Atoi(words[n]); err != nil || val < 0 {
+				fmt.Fprintln(f.out, "Error: Please enter a valid number of lines")
+				return false
+			} else {
+				lines = val
+			}
+		case "stdout":
+			stderr = false
+		case "stderr":
+			stderr = true
+		default:
+			args = append(args, words[n])
+		}
+	}
+	followed := false
+	f.WithInstance(strings.Join(args, " "), func(id, instance int) {
+		inst := f.GetInstance(id, instance)
+		if inst == nil {
+			return
+		}
+		b, path := inst.Output(stderr)
+		if b == nil && path == "" {
+			fmt.Fprintln(f.out, "Error:", inst, "output is not captured")
+			return
+		} else if path != "" {
+			if tail, err := output.Tail(path, lines); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintln(f.out, "Error:", err)
+				return
+			} else {
+				f.out.Write(tail)
+			}
+		}
+		if follow && b != nil {
+			f.Follow(inst, b)
+			followed = true
+		}
+	})
+	return followed
+}
+
+/*
+ * LastLog prints the recent output kept in memory for an instance, the
+ * first if none is given
+ */
+func (f *Frontend) LastLog(id, instance int) {
+	inst := f.GetInstance(id, instance)
+	if inst == nil {
+		return
+	}
+	if inst.Recent == nil {
+		fmt.Fprintln(f.out, "Error:", inst, "keeps no recent output")
+		return
+	}
+	for _, line := range inst.Recent.Lines(0) {
+		fmt.Fprintln(f.out, line)
+	}
+}
+
+/*
+ * Follow copies the output of an instance until SIGINT is received in the
+ * ui, until the control socket connection closes, or until the output is
+ * not read fast enough
+ */
+func (f *Frontend) Follow(inst *INST.Instance, b *output.Broadcaster) {
+	var once sync.Once
+	stop := make(chan struct{})
+	end := func() {
+		once.Do(func() { close(stop) })
+	}
+	if f.prompt != "" {
+		fmt.Fprintln(f.out, "Following", inst, "interrupt with Ctrl-C")
+		release := f.supervisor.InterceptInterrupt(end)
+		defer release()
+	} else {
+		go func() {
+			for f.scanner.Scan() {
+			}
+			end()
+		}()
+	}
+	detach := b.AttachClient(followWriter{f.out, end}, end)
+	defer detach()
+	<-stop
+}
+
+/*
+ * followWriter writes followed output, calling failed once writing fails as
+ * when a control socket connection closes
+ */
+type followWriter struct {
+	w      io.Writer
+	failed func()
+}
+
+func (fw followWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err != nil {
+		fw.failed()
+	}
+	return n, err
+}
+
 /*
  * FormatIDs returns the job commands used
  */
@@ -129,21 +455,99 @@ func (f *Frontend) FormatIDs() string {
 func (f *Frontend) FormatJobs() string {
 	jobs := make([]string, 0)
 	f.supervisor.ForAllJobs(func(job *JOB.Job) {
+		format := "%-12d%-12v%-12v%-12s%-18s%-12v%s\n"
+		next := "-"
+		if t := job.NextRun(); !t.IsZero() {
+			next = t.Format("2006-01-02 15:04")
+		}
+		running := false
 		for _, instance := range job.Instances {
-			if instance.Status != INST.PROCRUNNING || instance.Process == nil {
+			finished := instance.Status == INST.PROCSUCCEEDED ||
+				instance.Status == INST.PROCFAILED
+			live := instance.Status == INST.PROCRUNNING ||
+				instance.Status == INST.PROCPAUSED
+			if !live && !finished ||
+				instance.Process == nil {
 				continue
 			}
+			running = true
 			status := instance.GetStatus()
 			pid := instance.Process.Pid
-			format := "%-12d%-12v%-12d%-12s\n"
 			instanceId := instance.InstanceID
-			jobString := fmt.Sprintf(format, job.ID, instanceId, pid, status)
+			reason := lastExit(instance)
+			jobString := fmt.Sprintf(format, job.ID, instanceId, pid, status,
+				next, suppressed(instance), reason)
+			jobs = append(jobs, jobString)
+		}
+		if !running && job.Scheduler != nil {
+			jobString := fmt.Sprintf(format, job.ID, "-", "-", "scheduled",
+				next, "-", "")
 			jobs = append(jobs, jobString)
 		}
 	})
 	return strings.Join(jobs, "")
 }
 
+/*
+ * lastExit describes why an instance's previous process ended: the reason
+ * its watchdog restarted it & the signal which terminated it
+ */
+func lastExit(instance *INST.Instance) string {
+	defer instance.Mutex.RUnlock()
+	instance.Mutex.RLock()
+	reason := instance.ExitReason
+	if sig, ok := instance.TermSignal.(syscall.Signal); ok {
+		if reason != "" {
+			reason += ", "
+		}
+		reason += "killed by " + SIG.Name(sig)
+	}
+	return reason
+}
+
+/*
+ * suppressed describes the lines of output an instance's limits dropped,
+ * with the number of lines cut short, - if its output is not limited
+ */
+func suppressed(instance *INST.Instance) string {
+	if instance.Limiter == nil {
+		return "-"
+	}
+	count := strconv.FormatInt(instance.Limiter.Suppressed(), 10)
+	if cut := instance.Limiter.Truncated(); cut > 0 {
+		count += fmt.Sprintf(" (%d cut)", cut)
+	}
+	return count
+}
+
+/*
+ * FormatHistory returns the recent runs of a scheduled job
+ */
+func (f *Frontend) FormatHistory(id int) string {
+	job, err := f.supervisor.GetJob(id)
+	if err != nil {
+		return fmt.Sprintln(err)
+	} else if job.Scheduler == nil {
+		return fmt.Sprintln(job, "is not a scheduled job")
+	}
+	format := "%-12v%-24s%-16v%v\n"
+	runs := []string{fmt.Sprintf(format, "Instance", "Started", "Duration", "Exit")}
+	for _, run := range job.Runs() {
+		started := run.Start.Format("2006-01-02 15:04:05")
+		duration := run.Duration.Round(time.Millisecond)
+		exit := strconv.Itoa(run.ExitCode)
+		if run.Reason != "" {
+			exit += " (" + run.Reason + ")"
+		}
+		runs = append(runs, fmt.Sprintf(format, run.Instance, started,
+			duration, exit))
+		for _, line := range run.Output {
+			runs = append(runs, fmt.Sprintf("%-12v| %s\n", "", line))
+		}
+	}
+	return strings.Join(runs, "")
+}
+
 /*
  * SplitCommand detects and parses commands of different lengths
  */
@@ -166,9 +570,9 @@ func (f *Frontend) SplitCommand(input string) (id int) {
 func (f *Frontend) PrintLogs() {
 	data, err := ioutil.ReadFile(f.supervisor.LogFile)
 	if err != nil {
-		fmt.Print(err)
+		fmt.Fprint(f.out, err)
 	}
-	fmt.Print(string(data))
+	fmt.Fprint(f.out, string(data))
 }
 
 /*
@@ -182,10 +586,12 @@ func (f *Frontend) GetIdFromDefault(input string) (id int) {
 		case err != nil:
 			fallthrough
 		case !f.supervisor.HasJob(id):
-			fmt.Println("Error: Please enter a valid ID")
-			fmt.Print(f.FormatIDs())
-			fmt.Print("> ")
-			f.scanner.Scan()
+			fmt.Fprintln(f.out, "Error: Please enter a valid ID")
+			fmt.Fprint(f.out, f.FormatIDs())
+			fmt.Fprint(f.out, f.prompt)
+			if !f.scanner.Scan() {
+				return -1
+			}
 			input = f.scanner.Text()
 		default:
 			return
@@ -199,17 +605,19 @@ func (f *Frontend) GetIdFromDefault(input string) (id int) {
 func (f *Frontend) GetId() (id int) {
 	var err error
 	for {
-		fmt.Println("Please select an ID")
-		fmt.Print(f.FormatIDs())
-		fmt.Print("> ")
-		f.scanner.Scan()
+		fmt.Fprintln(f.out, "Please select an ID")
+		fmt.Fprint(f.out, f.FormatIDs())
+		fmt.Fprint(f.out, f.prompt)
+		if !f.scanner.Scan() {
+			return -1
+		}
 		input := f.scanner.Text()
 		id, err = strconv.Atoi(input)
 		switch {
 		case err != nil:
 			fallthrough
 		case !f.supervisor.HasJob(id):
-			fmt.Println("Error: Please enter a valid ID")
+			fmt.Fprintln(f.out, "Error: Please enter a valid ID")
 		default:
 			return
 		}
@@ -220,14 +628,26 @@ func (f *Frontend) GetId() (id int) {
  * PrintHelp displays the program usage
  */
 func (f *Frontend) PrintHelp() {
-	fmt.Println("Commands:")
-	fmt.Println("ps:         List current jobs being managed")
-	fmt.Println("logs:       display jobs logs")
-	fmt.Println("clear:      clear the screen")
-	fmt.Println("start [id]: start given job")
-	fmt.Println("stop [id]:  stop given job")
-	fmt.Println("startAll:   start all jobs")
-	fmt.Println("stopAll:    stop all jobs")
-	fmt.Println("reload:     reload the configur file")
-	fmt.Println("exit:       stop all jobs and exit taskmaster")
+	fmt.Fprintln(f.out, "Commands:")
+	fmt.Fprintln(f.out, "ps:         List current jobs being managed")
+	fmt.Fprintln(f.out, "logs:       display jobs logs")
+	fmt.Fprintln(f.out, "clear:      clear the screen")
+	fmt.Fprintln(f.out, "start [id]: start given job")
+	fmt.Fprintln(f.out, "start [id] --wait: start given job, wait for it to finish")
+	fmt.Fprintln(f.out, "stop [id]:  stop given job")
+	fmt.Fprintln(f.out, "pause [id] [instance]: freeze the instances of given job")
+	fmt.Fprintln(f.out, "resume [id] [instance]: continue paused instances of given job")
+	fmt.Fprintln(f.out, "attach [id] [instance]: connect to an interactive instance, detach with Ctrl-] then Enter")
+	fmt.Fprintln(f.out, "signal <SIG> [id] [instance] [--group]: send a signal to given job")
+	fmt.Fprintln(f.out, "resize <cols>x<rows> [id] [instance]: set the terminal size of a tty job")
+	fmt.Fprintln(f.out, "tail [-f] [-n N] [id] [instance] [stdout|stderr]: print the last lines of given job's output, -f follows it until Ctrl-C")
+	fmt.Fprintln(f.out, "lastlog [id] [instance]: print the recent output kept in memory for given job")
+	fmt.Fprintln(f.out, "history [id]: list recent runs of a scheduled job")
+	fmt.Fprintln(f.out, "startAll:   start all jobs")
+	fmt.Fprintln(f.out, "stopAll:    stop all jobs")
+	fmt.Fprintln(f.out, "reload:     reload the configur file")
+	fmt.Fprintln(f.out, "upgrade:    re-execute taskmaster without stopping jobs")
+	fmt.Fprintln(f.out, "loglevel [LEVEL] [package=LEVEL...]: show or change the levels logged, of all packages or of one, e.g. instance=DEBUG")
+	fmt.Fprintln(f.out, "reopen-logs: reopen the log & output files after they were rotated")
+	fmt.Fprintln(f.out, "exit:       stop all jobs and exit taskmaster")
 }
@@ -14,6 +14,8 @@ import (
 
 var Buf bytes.Buffer
 
+var mockLevels logging.LeveledBackend
+
 func FileContains(file string) (string, error) {
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -33,9 +35,14 @@ func MockLogger(out string) {
 		logging.NewLogBackend(logOut, "", 0),
 		logging.MustStringFormatter(`%{message}`))
 	Log, _ = logging.GetLogger("taskmaster")
-	leveledBackend := logging.AddModuleLevel(loggingBackend)
+	leveledBackend := logging.AddModuleLevel(NewFieldsBackend(loggingBackend))
 	leveledBackend.SetLevel(logging.DEBUG, "")
 	Log.SetBackend(leveledBackend)
+	mockLevels = leveledBackend
+}
+
+func MockLevel(level logging.Level) {
+	mockLevels.SetLevel(level, "")
 }
 
 func LogsContain(t *testing.T, logs string, logStrings []string) {